@@ -4,17 +4,37 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/samverrall/review-ui/internal/git"
 	"github.com/samverrall/review-ui/internal/ui"
 )
 
 func main() {
 	// Parse command line flags
 	debug := flag.Bool("debug", false, "enable debug logging to debug.log")
+	staged := flag.Bool("staged", false, "review changes staged in the index")
+	commit := flag.String("commit", "", "review the changes introduced by a single commit")
+	revRange := flag.String("range", "", "review the changes between two revisions (base..head)")
+	mergeBase := flag.String("merge-base", "", "review HEAD against its merge base with a branch, like a pull request")
+	watch := flag.Duration("watch", time.Second, "how often to check for changes on disk, 0 to disable")
+	exportFormat := flag.String("export-format", "markdown", "format comments are exported in: markdown, json or sarif")
+	exportSeverity := flag.String("export-severity", "", "only export comments with these comma separated severities, e.g. blocking,question")
+	groupBySeverity := flag.Bool("group-by-severity", false, "group exported comments by severity, blocking first")
+	exportCode := flag.Bool("export-code", true, "include the commented code with each exported comment")
+	exportContext := flag.Int("export-context", 0, "lines of surrounding code to include with the exported code")
+	exportMarkers := flag.Bool("export-markers", false, "keep the +/- markers on the exported code")
 	flag.Parse()
 
+	mode, err := diffModeFromFlags(*staged, *commit, *revRange, *mergeBase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Set up logger
 	logger := setupLogger(*debug)
 	if *debug {
@@ -22,7 +42,19 @@ func main() {
 	}
 
 	// Create the model
-	m, err := ui.NewWithLogger(logger)
+	opts := ui.Options{
+		Mode:              mode,
+		WatchInterval:     *watch,
+		ExportFormat:      *exportFormat,
+		GroupBySeverity:   *groupBySeverity,
+		ExportCode:        *exportCode,
+		ExportContext:     *exportContext,
+		ExportDiffMarkers: *exportMarkers,
+	}
+	if *exportSeverity != "" {
+		opts.ExportSeverities = strings.Split(*exportSeverity, ",")
+	}
+	m, err := ui.NewWithOptions(opts, logger)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -41,3 +73,33 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// diffModeFromFlags picks the review mode from the command line, allowing at most one mode flag
+func diffModeFromFlags(staged bool, commit, revRange, mergeBase string) (git.DiffMode, error) {
+	var modes []git.DiffMode
+	if staged {
+		modes = append(modes, git.Staged())
+	}
+	if commit != "" {
+		modes = append(modes, git.Commit(commit))
+	}
+	if revRange != "" {
+		mode, err := git.Range(revRange)
+		if err != nil {
+			return git.DiffMode{}, err
+		}
+		modes = append(modes, mode)
+	}
+	if mergeBase != "" {
+		modes = append(modes, git.MergeBase(mergeBase))
+	}
+
+	switch len(modes) {
+	case 0:
+		return git.WorkingTree(), nil
+	case 1:
+		return modes[0], nil
+	default:
+		return git.DiffMode{}, fmt.Errorf("only one of --staged, --commit, --range and --merge-base may be used")
+	}
+}
@@ -0,0 +1,155 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Number of lines captured on each side of the commented lines
+const anchorContextLines = 3
+
+// Anchor captures the code a comment was written against, so the comment can be found
+// again after the diff changes and its line numbers no longer apply
+type Anchor struct {
+	Lines  []string `json:"lines"`            // Content of the commented lines
+	Before []string `json:"before,omitempty"` // Lines shown before the commented lines, nearest last
+	After  []string `json:"after,omitempty"`  // Lines shown after the commented lines, nearest first
+	Hunk   string   `json:"hunk,omitempty"`   // Section heading of the hunk, e.g. the enclosing function
+}
+
+// sideLine is a line of one side of a diff, in display order
+type sideLine struct {
+	number  int
+	content string
+	hunk    *Hunk
+}
+
+// sideLines returns the lines of the rows that exist on the given side
+func sideLines(rows []Row, side Side) []sideLine {
+	var lines []sideLine
+	for _, row := range rows {
+		if n := row.Number(side); n != 0 && row.Line != nil {
+			lines = append(lines, sideLine{number: n, content: row.Line.Content, hunk: row.Hunk})
+		}
+	}
+	return lines
+}
+
+// CaptureAnchor records the lines start to end on one side of a diff along with the
+// surrounding lines and hunk heading. It returns an empty anchor if none of the lines are shown.
+func CaptureAnchor(rows []Row, side Side, start, end int) Anchor {
+	lines := sideLines(rows, side)
+	first, last := -1, -1
+	for i, line := range lines {
+		if line.number >= start && line.number <= end {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return Anchor{}
+	}
+
+	var anchor Anchor
+	for _, line := range lines[first : last+1] {
+		anchor.Lines = append(anchor.Lines, line.content)
+	}
+	for _, line := range lines[max(first-anchorContextLines, 0):first] {
+		anchor.Before = append(anchor.Before, line.content)
+	}
+	for _, line := range lines[last+1 : min(last+1+anchorContextLines, len(lines))] {
+		anchor.After = append(anchor.After, line.content)
+	}
+	if hunk := lines[first].hunk; hunk != nil {
+		anchor.Hunk = hunk.Section
+	}
+	return anchor
+}
+
+// IsEmpty reports whether the anchor has no lines to look for
+func (a Anchor) IsEmpty() bool {
+	return len(a.Lines) == 0
+}
+
+// Locate finds the anchored lines on one side of a diff and returns their new line range.
+// Where the lines appear more than once, the match with the most matching surrounding lines
+// wins, then the one nearest the previous start line. Matches with no matching context are
+// only trusted if they're the only match and the lines aren't just punctuation, so a comment
+// is never moved to an unrelated "}" or blank line.
+func (a Anchor) Locate(rows []Row, side Side, previousStart int) (start, end int, ok bool) {
+	if a.IsEmpty() {
+		return 0, 0, false
+	}
+
+	lines := sideLines(rows, side)
+	bestScore, bestDistance, candidates := -1, 0, 0
+	for i := 0; i+len(a.Lines) <= len(lines); i++ {
+		if !sameLines(lines[i:i+len(a.Lines)], a.Lines) {
+			continue
+		}
+		candidates++
+
+		score := 0
+		for k := 1; k <= len(a.Before) && i-k >= 0; k++ {
+			if sameLine(lines[i-k].content, a.Before[len(a.Before)-k]) {
+				score++
+			}
+		}
+		next := i + len(a.Lines)
+		for k := 0; k < len(a.After) && next+k < len(lines); k++ {
+			if sameLine(lines[next+k].content, a.After[k]) {
+				score++
+			}
+		}
+		if a.Hunk != "" && lines[i].hunk != nil && lines[i].hunk.Section == a.Hunk {
+			score++
+		}
+
+		distance := abs(lines[i].number - previousStart)
+		if score > bestScore || (score == bestScore && distance < bestDistance) {
+			bestScore, bestDistance = score, distance
+			start, end = lines[i].number, lines[next-1].number
+		}
+	}
+
+	if candidates == 0 || (bestScore == 0 && (candidates > 1 || !hasWords(a.Lines))) {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// sameLines reports whether the side lines have the given contents
+func sameLines(lines []sideLine, contents []string) bool {
+	for i, line := range lines {
+		if !sameLine(line.content, contents[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameLine compares two lines ignoring indentation and trailing whitespace, which are
+// often changed by formatters without changing the code
+func sameLine(a, b string) bool {
+	return strings.TrimSpace(a) == strings.TrimSpace(b)
+}
+
+// hasWords reports whether any of the lines contains a letter or digit
+func hasWords(lines []string) bool {
+	for _, line := range lines {
+		if strings.IndexFunc(line, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+)
+
+// anchorDiff builds a diff of a single hunk of context lines starting at line start
+func anchorDiff(t *testing.T, start int, section string, lines ...string) []Row {
+	t.Helper()
+	raw := fmt.Sprintf("--- a/f.go\n+++ b/f.go\n@@ -%d,%d +%d,%d @@ %s\n", start, len(lines), start, len(lines), section)
+	for _, line := range lines {
+		raw += " " + line + "\n"
+	}
+	files, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	return Rows(files)
+}
+
+func TestAnchorLocate(t *testing.T) {
+	original := anchorDiff(t, 10, "func a() {", "x := 1", "y := 2", "return x + y", "}")
+	anchor := CaptureAnchor(original, SideNew, 11, 12)
+	if len(anchor.Lines) != 2 || anchor.Lines[0] != "y := 2" || anchor.Before[0] != "x := 1" || anchor.After[0] != "}" {
+		t.Fatalf("unexpected anchor: %+v", anchor)
+	}
+	if anchor.Hunk != "func a() {" {
+		t.Errorf("expected the hunk section to be captured, got %q", anchor.Hunk)
+	}
+
+	tests := []struct {
+		name      string
+		rows      []Row
+		wantStart int
+		wantEnd   int
+		wantOK    bool
+	}{
+		{
+			name:      "unchanged",
+			rows:      original,
+			wantStart: 11, wantEnd: 12, wantOK: true,
+		},
+		{
+			name:      "shifted down and reindented",
+			rows:      anchorDiff(t, 20, "func a() {", "// added", "x := 1", "    y := 2", "return x + y", "}"),
+			wantStart: 22, wantEnd: 23, wantOK: true,
+		},
+		{
+			name: "duplicated code prefers matching context",
+			rows: anchorDiff(t, 1, "func b() {", "z := 0", "y := 2", "return x + y", "w := 3", "x := 1", "y := 2", "return x + y", "}"),
+			// The second copy has the original lines before and after it
+			wantStart: 6, wantEnd: 7, wantOK: true,
+		},
+		{
+			name:   "code removed",
+			rows:   anchorDiff(t, 10, "func a() {", "x := 1", "y := 3", "return x + y", "}"),
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := anchor.Locate(tt.rows, SideNew, 11)
+			if ok != tt.wantOK || (ok && (start != tt.wantStart || end != tt.wantEnd)) {
+				t.Errorf("expected %d-%d (ok=%v), got %d-%d (ok=%v)", tt.wantStart, tt.wantEnd, tt.wantOK, start, end, ok)
+			}
+		})
+	}
+}
+
+func TestAnchorLocateRejectsAmbiguousPunctuation(t *testing.T) {
+	anchor := CaptureAnchor(anchorDiff(t, 1, "", "a()", "}", "b()"), SideNew, 2, 2)
+
+	// Only a "}" with different surroundings remains; it's not safe to assume it's the same one
+	if _, _, ok := anchor.Locate(anchorDiff(t, 1, "", "c()", "}", "d()"), SideNew, 2); ok {
+		t.Errorf("expected a lone brace without matching context to be outdated")
+	}
+}
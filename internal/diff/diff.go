@@ -0,0 +1,185 @@
+package diff
+
+import "fmt"
+
+// LineKind classifies a single row of a unified diff
+type LineKind int
+
+const (
+	KindHeader    LineKind = iota // File headers (diff --git, index, ---, +++) and other metadata
+	KindHunk                      // Hunk header (@@ -a,b +c,d @@)
+	KindContext                   // Unchanged line present in both versions
+	KindAdded                     // Line only present in the new version
+	KindDeleted                   // Line only present in the old version
+	KindNoNewline                 // "\ No newline at end of file" marker
+)
+
+// Side identifies which version of a file a line number refers to
+type Side int
+
+const (
+	SideNew Side = iota // The new (right hand) version of the file
+	SideOld             // The old (left hand) version of the file
+)
+
+// String returns a short human readable name for the side
+func (s Side) String() string {
+	if s == SideOld {
+		return "old"
+	}
+	return "new"
+}
+
+// MarshalText encodes the side as "old" or "new", e.g. in saved sessions
+func (s Side) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a side encoded by MarshalText
+func (s *Side) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "new":
+		*s = SideNew
+	case "old":
+		*s = SideOld
+	default:
+		return fmt.Errorf("unknown side %q", text)
+	}
+	return nil
+}
+
+// File is the parsed diff of a single file
+type File struct {
+	OldName    string   // Path before the change ("" for added files)
+	NewName    string   // Path after the change ("" for deleted files)
+	OldMode    string   // File mode before the change, e.g. "100644"
+	NewMode    string   // File mode after the change
+	IsNew      bool     // File was added
+	IsDeleted  bool     // File was deleted
+	IsRename   bool     // File was renamed from OldName
+	IsCopy     bool     // File was copied from OldName
+	Similarity int      // Similarity index for renames and copies (0-100)
+	IsBinary   bool     // Binary file, no hunks available
+	IsCombined bool     // Combined diff of a merge conflict against several parents, no hunks available
+	Headers    []string // Raw header lines in their original order
+	Hunks      []*Hunk  // Content changes
+}
+
+// Name returns the path of the file, preferring the new path unless the file was deleted
+func (f *File) Name() string {
+	if f.NewName != "" {
+		return f.NewName
+	}
+	return f.OldName
+}
+
+// ModeChanged reports whether the file mode changed without the file being added or deleted
+func (f *File) ModeChanged() bool {
+	return f.OldMode != "" && f.NewMode != "" && f.OldMode != f.NewMode
+}
+
+// Stats returns the number of added and deleted lines in the file
+func (f *File) Stats() (added, deleted int) {
+	for _, hunk := range f.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case KindAdded:
+				added++
+			case KindDeleted:
+				deleted++
+			}
+		}
+	}
+	return added, deleted
+}
+
+// Hunk is a single @@ section of a file diff
+type Hunk struct {
+	Header   string // Raw hunk header line
+	OldStart int    // First line of the hunk in the old file
+	OldLines int    // Number of old lines covered by the hunk
+	NewStart int    // First line of the hunk in the new file
+	NewLines int    // Number of new lines covered by the hunk
+	Section  string // Optional section heading after the closing @@ (e.g. a function signature)
+	Lines    []*Line
+}
+
+// Line is a single context, added or deleted line within a hunk
+type Line struct {
+	Kind      LineKind // KindContext, KindAdded or KindDeleted
+	Content   string   // Line text without the leading +, - or space
+	OldLine   int      // Line number in the old file, 0 for added lines
+	NewLine   int      // Line number in the new file, 0 for deleted lines
+	NoNewline bool     // Line is not terminated by a newline
+	Changed   []Span   // Words that differ from the paired deleted/added line, for intra-line highlighting
+}
+
+// Row is a single rendered line of a diff, as displayed in the viewport.
+// A line number of 0 means the row has no counterpart on that side.
+type Row struct {
+	Kind    LineKind
+	Text    string // Raw text of the row, including any +/- prefix
+	OldLine int
+	NewLine int
+	File    *File // File the row belongs to
+	Hunk    *Hunk // Hunk the row belongs to, nil for file headers
+	Line    *Line // Diff line for content rows, nil otherwise
+}
+
+// Number returns the line number of the row on the given side
+func (r Row) Number(side Side) int {
+	if side == SideOld {
+		return r.OldLine
+	}
+	return r.NewLine
+}
+
+// Rows flattens the file into display rows: headers, then each hunk header followed by its lines
+func (f *File) Rows() []Row {
+	var rows []Row
+	for _, header := range f.Headers {
+		rows = append(rows, Row{Kind: KindHeader, Text: header, File: f})
+	}
+	if f.IsCombined {
+		rows = append(rows, Row{Kind: KindHeader, Text: combinedDiffNotice, File: f})
+	}
+	for _, hunk := range f.Hunks {
+		rows = append(rows, Row{Kind: KindHunk, Text: hunk.Header, File: f, Hunk: hunk})
+		for _, line := range hunk.Lines {
+			rows = append(rows, Row{
+				Kind:    line.Kind,
+				Text:    line.prefix() + line.Content,
+				OldLine: line.OldLine,
+				NewLine: line.NewLine,
+				File:    f,
+				Hunk:    hunk,
+				Line:    line,
+			})
+			if line.NoNewline {
+				rows = append(rows, Row{Kind: KindNoNewline, Text: noNewlineMarker, File: f, Hunk: hunk})
+			}
+		}
+	}
+	return rows
+}
+
+// Rows flattens several files into display rows
+func Rows(files []*File) []Row {
+	var rows []Row
+	for _, f := range files {
+		rows = append(rows, f.Rows()...)
+	}
+	return rows
+}
+
+// prefix returns the unified diff marker for the line
+func (l *Line) prefix() string {
+	switch l.Kind {
+	case KindAdded:
+		return "+"
+	case KindDeleted:
+		return "-"
+	default:
+		return " "
+	}
+}
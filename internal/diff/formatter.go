@@ -2,7 +2,6 @@ package diff
 
 import (
 	"log/slog"
-	"regexp"
 	"strings"
 	"sync"
 
@@ -16,12 +15,18 @@ var (
 	deletionStyle = lipgloss.NewStyle().Foreground(color.MoonRed)    // Soft red (moon theme)
 	hunkStyle     = lipgloss.NewStyle().Foreground(color.MoonBlue)   // Soft blue (moon theme)
 	headerStyle   = lipgloss.NewStyle().Foreground(color.MoonPurple) // Muted purple (moon theme)
+
+	separatorStyle = lipgloss.NewStyle().Foreground(color.MoonDarkGray)   // Column separator in the split view
+	emptyCellStyle = lipgloss.NewStyle().Foreground(color.MoonDarkerGray) // Filler for lines missing on one side
+	gutterStyle    = lipgloss.NewStyle().Foreground(color.MoonGray)       // Line numbers
 )
 
+// SGR sequences emphasizing changed words. They only set the background, so the
+// syntax colors of the words show through.
 var (
-	// Regex to match diff headers and extract filenames
-	diffHeaderRegex = regexp.MustCompile(`^diff --git a/(.+) b/(.+)$`)
-	fileHeaderRegex = regexp.MustCompile(`^(---|\+\+\+) (.+)$`)
+	addedEmphasis   = "\x1b[48;5;" + string(color.AddedEmphasisBg) + "m"
+	deletedEmphasis = "\x1b[48;5;" + string(color.DeletedEmphasisBg) + "m"
+	emphasisOff     = "\x1b[49m"
 )
 
 var (
@@ -37,96 +42,73 @@ func getHighlighter() *syntax.Highlighter {
 	return highlighter
 }
 
-// FormatDiff applies ANSI color formatting to a git diff string with syntax highlighting
-func FormatDiff(width int, diff string, logger *slog.Logger) string {
-	if diff == "" {
+// Render applies ANSI color formatting and syntax highlighting to diff rows,
+// producing exactly one output line per row. Every row except file headers starts
+// with a gutter showing its old and new line numbers.
+func Render(width int, rows []Row, logger *slog.Logger) string {
+	if len(rows) == 0 {
 		return ""
 	}
 
 	h := getHighlighter()
-	lines := strings.Split(diff, "\n")
-	var formatted []string
-
-	currentFile := "" // Track the current file for syntax highlighting
+	digits := LineNumberDigits(rows)
+	formatted := make([]string, 0, len(rows))
 
-	for _, line := range lines {
+	for _, row := range rows {
 		var styledLine string
 
-		switch {
-		case strings.HasPrefix(line, "+"):
-			// Addition line - apply syntax highlighting to code, keep + green
-			code := strings.TrimPrefix(line, "+")
-			if currentFile != "" && code != "" {
-				highlighted, err := h.Highlight(currentFile, code)
-				if err == nil {
-					// Green + symbol followed by syntax-highlighted code
-					styledLine = additionStyle.Render("+") + highlighted
-				} else {
-					logger.Debug("syntax highlighting failed for addition",
-						"file", currentFile,
-						"error", err)
-					styledLine = additionStyle.Render(line)
-				}
-			} else {
-				styledLine = additionStyle.Render(line)
-			}
-		case strings.HasPrefix(line, "-"):
-			// Deletion line - apply syntax highlighting to code, keep - red
-			code := strings.TrimPrefix(line, "-")
-			if currentFile != "" && code != "" {
-				highlighted, err := h.Highlight(currentFile, code)
-				if err == nil {
-					// Red - symbol followed by syntax-highlighted code
-					styledLine = deletionStyle.Render("-") + highlighted
-				} else {
-					logger.Debug("syntax highlighting failed for deletion",
-						"file", currentFile,
-						"error", err)
-					styledLine = deletionStyle.Render(line)
-				}
-			} else {
-				styledLine = deletionStyle.Render(line)
-			}
-		case strings.HasPrefix(line, "@@"):
+		// Track the current file for syntax highlighting
+		currentFile := ""
+		if row.File != nil {
+			currentFile = row.File.Name()
+		}
+
+		switch row.Kind {
+		case KindAdded:
+			// Addition line - apply syntax highlighting to code, keep + green, emphasize changed words
+			styledLine = highlightLine(h, currentFile, row.Line.Content, "+", additionStyle, logger)
+			styledLine = emphasize(styledLine, row.Line.Changed, 1, addedEmphasis, emphasisOff)
+		case KindDeleted:
+			// Deletion line - apply syntax highlighting to code, keep - red, emphasize changed words
+			styledLine = highlightLine(h, currentFile, row.Line.Content, "-", deletionStyle, logger)
+			styledLine = emphasize(styledLine, row.Line.Changed, 1, deletedEmphasis, emphasisOff)
+		case KindHunk:
 			// Hunk header - keep existing styling
-			styledLine = hunkStyle.Render(line)
-		case strings.HasPrefix(line, "diff "):
-			// Diff header - extract filename for syntax highlighting
-			styledLine = headerStyle.Width(width).Render(line)
-			if matches := diffHeaderRegex.FindStringSubmatch(line); len(matches) >= 3 {
-				// Use the "b/" version (new file) for syntax highlighting
-				currentFile = matches[2]
-			}
-		case strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++"):
-			// File header - keep existing styling
-			styledLine = headerStyle.Width(width).Render(line)
-			if matches := fileHeaderRegex.FindStringSubmatch(line); len(matches) >= 3 {
-				// Update current file if this is a +++ line (new file)
-				if strings.HasPrefix(line, "+++") && matches[2] != "/dev/null" {
-					currentFile = strings.TrimPrefix(matches[2], "b/")
-				}
-			}
+			styledLine = hunkStyle.Render(row.Text)
+		case KindHeader:
+			// File header - limited to a single line so rows stay aligned with the viewport
+			styledLine = headerStyle.Width(width).MaxHeight(1).Render(row.Text)
+		case KindNoNewline:
+			styledLine = hunkStyle.Render(row.Text)
 		default:
 			// Context line - apply syntax highlighting without tint
-			if currentFile != "" {
-				highlighted, err := h.Highlight(currentFile, line)
-				if err == nil {
-					styledLine = highlighted
-				} else {
-					// Log syntax highlighting failures for debugging
-					logger.Debug("syntax highlighting failed",
-						"file", currentFile,
-						"line", line,
-						"error", err)
-					styledLine = line
-				}
-			} else {
-				styledLine = line
-			}
+			styledLine = highlightLine(h, currentFile, row.Line.Content, " ", lipgloss.NewStyle(), logger)
 		}
 
+		if row.Kind != KindHeader {
+			styledLine = gutter(digits, row.OldLine, row.NewLine) + styledLine
+		}
 		formatted = append(formatted, styledLine)
 	}
 
 	return strings.Join(formatted, "\n")
 }
+
+// highlightLine renders a diff line as a styled marker followed by syntax-highlighted code
+func highlightLine(h *syntax.Highlighter, filename, code, marker string, markerStyle lipgloss.Style, logger *slog.Logger) string {
+	if filename == "" || code == "" {
+		return markerStyle.Render(marker + code)
+	}
+
+	highlighted, err := h.Highlight(filename, code)
+	if err != nil {
+		// Log syntax highlighting failures for debugging
+		logger.Debug("syntax highlighting failed",
+			"file", filename,
+			"line", code,
+			"error", err)
+		return markerStyle.Render(marker + code)
+	}
+
+	return markerStyle.Render(marker) + highlighted
+}
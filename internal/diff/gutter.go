@@ -0,0 +1,39 @@
+package diff
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// gutterSeparator is drawn between the line number gutter and the code
+const gutterSeparator = " │ "
+
+// LineNumberDigits returns the number of digits needed to show every line number of the rows,
+// so the gutter has the same width on every row of a diff
+func LineNumberDigits(rows []Row) int {
+	digits := 1
+	for _, row := range rows {
+		digits = max(digits, len(strconv.Itoa(row.OldLine)), len(strconv.Itoa(row.NewLine)))
+	}
+	return digits
+}
+
+// gutter renders the old and new line number columns of a unified row, leaving a column
+// blank when the row has no line on that side
+func gutter(digits, oldLine, newLine int) string {
+	return gutterStyle.Render(fmt.Sprintf("%*s %*s", digits, lineNumberText(oldLine), digits, lineNumberText(newLine))) +
+		separatorStyle.Render(gutterSeparator)
+}
+
+// splitGutter renders the line number column of one side of a side-by-side row
+func splitGutter(digits, line int) string {
+	return gutterStyle.Render(fmt.Sprintf("%*s ", digits, lineNumberText(line)))
+}
+
+// lineNumberText formats a line number for the gutter, with 0 (no line) shown as blank
+func lineNumberText(line int) string {
+	if line == 0 {
+		return ""
+	}
+	return strconv.Itoa(line)
+}
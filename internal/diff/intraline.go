@@ -0,0 +1,223 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Lines with more tokens than this aren't compared word by word, to bound the cost of the diff
+const maxIntraLineTokens = 500
+
+// Span is a byte range [Start, End) of a line's content
+type Span struct {
+	Start int
+	End   int
+}
+
+// markIntraLineChanges pairs the deletions and additions of each block of changes in a hunk,
+// in order, and records which words differ between the two lines of each pair
+func markIntraLineChanges(hunk *Hunk) {
+	var deleted, added []*Line
+
+	flush := func() {
+		for i := 0; i < len(deleted) && i < len(added); i++ {
+			deleted[i].Changed, added[i].Changed = IntraLineChanges(deleted[i].Content, added[i].Content)
+		}
+		deleted, added = nil, nil
+	}
+
+	for _, line := range hunk.Lines {
+		switch line.Kind {
+		case KindDeleted:
+			// A deletion after additions starts a new block
+			if len(added) > 0 {
+				flush()
+			}
+			deleted = append(deleted, line)
+		case KindAdded:
+			added = append(added, line)
+		default:
+			flush()
+		}
+	}
+	flush()
+}
+
+// IntraLineChanges compares two versions of a line word by word and returns the byte
+// ranges of each that differ. Lines sharing fewer than half their words are treated as
+// rewritten and return no ranges, since highlighting nearly every word would add nothing.
+func IntraLineChanges(oldText, newText string) ([]Span, []Span) {
+	if oldText == newText {
+		return nil, nil
+	}
+
+	oldTokens, newTokens := tokenizeLine(oldText), tokenizeLine(newText)
+	if len(oldTokens) > maxIntraLineTokens || len(newTokens) > maxIntraLineTokens {
+		return nil, nil
+	}
+
+	// Longest common subsequence of tokens, filled from the end so it can be walked forwards
+	lcs := make([][]int, len(oldTokens)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newTokens)+1)
+	}
+	for i := len(oldTokens) - 1; i >= 0; i-- {
+		for j := len(newTokens) - 1; j >= 0; j-- {
+			if oldTokens[i].text == newTokens[j].text {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var oldChanged, newChanged []Span
+	common := 0
+	i, j := 0, 0
+	for i < len(oldTokens) || j < len(newTokens) {
+		switch {
+		case i < len(oldTokens) && j < len(newTokens) && oldTokens[i].text == newTokens[j].text:
+			if !oldTokens[i].isSpace() {
+				common++
+			}
+			i++
+			j++
+		case j < len(newTokens) && (i == len(oldTokens) || lcs[i][j+1] >= lcs[i+1][j]):
+			newChanged = appendSpan(newChanged, newTokens[j].span)
+			j++
+		default:
+			oldChanged = appendSpan(oldChanged, oldTokens[i].span)
+			i++
+		}
+	}
+
+	if common*2 < min(countWords(oldTokens), countWords(newTokens)) {
+		return nil, nil
+	}
+	return oldChanged, newChanged
+}
+
+// appendSpan adds a span, merging it with the previous one when they touch
+func appendSpan(spans []Span, span Span) []Span {
+	if last := len(spans) - 1; last >= 0 && spans[last].End == span.Start {
+		spans[last].End = span.End
+		return spans
+	}
+	return append(spans, span)
+}
+
+// lineToken is a word, run of whitespace or single punctuation character of a line
+type lineToken struct {
+	text string
+	span Span
+}
+
+// isSpace reports whether the token is a run of whitespace
+func (t lineToken) isSpace() bool {
+	return strings.TrimSpace(t.text) == ""
+}
+
+// countWords returns the number of tokens that aren't whitespace
+func countWords(tokens []lineToken) int {
+	count := 0
+	for _, token := range tokens {
+		if !token.isSpace() {
+			count++
+		}
+	}
+	return count
+}
+
+// tokenizeLine splits a line into identifiers and numbers, runs of whitespace and single
+// punctuation characters, so changing one identifier highlights just that identifier
+func tokenizeLine(text string) []lineToken {
+	var tokens []lineToken
+	for start := 0; start < len(text); {
+		r, size := utf8.DecodeRuneInString(text[start:])
+		end := start + size
+
+		var class func(rune) bool
+		switch {
+		case isWordRune(r):
+			class = isWordRune
+		case unicode.IsSpace(r):
+			class = unicode.IsSpace
+		}
+		for class != nil && end < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[end:])
+			if !class(next) {
+				break
+			}
+			end += nextSize
+		}
+
+		tokens = append(tokens, lineToken{text: text[start:end], span: Span{Start: start, End: end}})
+		start = end
+	}
+	return tokens
+}
+
+// isWordRune reports whether r can be part of an identifier or number
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// emphasize applies an extra SGR sequence to the given spans of a styled line, whose
+// visible text starts offset bytes before the spans' line content (e.g. a +/- marker).
+// Syntax highlighting resets all attributes after each token, so the emphasis is
+// restored after every escape sequence inside a span.
+func emphasize(styled string, spans []Span, offset int, on, off string) string {
+	if len(spans) == 0 {
+		return styled
+	}
+
+	var b strings.Builder
+	pos, span, active := 0, 0, false
+	for i := 0; i < len(styled); {
+		if styled[i] == '\x1b' {
+			end := escapeSequenceEnd(styled, i)
+			b.WriteString(styled[i:end])
+			if active {
+				b.WriteString(on)
+			}
+			i = end
+			continue
+		}
+
+		for span < len(spans) && pos >= spans[span].End+offset {
+			if active {
+				b.WriteString(off)
+				active = false
+			}
+			span++
+		}
+		if span < len(spans) && !active && pos >= spans[span].Start+offset {
+			b.WriteString(on)
+			active = true
+		}
+
+		b.WriteByte(styled[i])
+		pos++
+		i++
+	}
+	if active {
+		b.WriteString(off)
+	}
+
+	return b.String()
+}
+
+// escapeSequenceEnd returns the index just past the escape sequence starting at i
+func escapeSequenceEnd(s string, i int) int {
+	if i+1 >= len(s) || s[i+1] != '[' {
+		return min(i+2, len(s))
+	}
+	// CSI sequences end with a byte in the range 0x40-0x7E
+	for j := i + 2; j < len(s); j++ {
+		if s[j] >= 0x40 && s[j] <= 0x7e {
+			return j + 1
+		}
+	}
+	return len(s)
+}
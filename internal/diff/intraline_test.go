@@ -0,0 +1,76 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIntraLineChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		wantOld  string
+		wantNew  string
+	}{
+		{
+			name:    "renamed identifier",
+			old:     "\tresult := computeTotal(items, tax)",
+			new:     "\tresult := computeSum(items, tax)",
+			wantOld: "[computeTotal]",
+			wantNew: "[computeSum]",
+		},
+		{
+			name:    "inserted argument",
+			old:     "call(a, b)",
+			new:     "call(a, x, b)",
+			wantOld: "[]",
+			wantNew: "[x, ]",
+		},
+		{
+			name:    "unicode words",
+			old:     `msg := "héllo wörld"`,
+			new:     `msg := "héllo welt"`,
+			wantOld: "[wörld]",
+			wantNew: "[welt]",
+		},
+		{
+			name:    "rewritten line",
+			old:     "return nil",
+			new:     "panic(err)",
+			wantOld: "[]",
+			wantNew: "[]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldSpans, newSpans := IntraLineChanges(tt.old, tt.new)
+			if got := spanText(tt.old, oldSpans); got != tt.wantOld {
+				t.Errorf("old: expected %s, got %s", tt.wantOld, got)
+			}
+			if got := spanText(tt.new, newSpans); got != tt.wantNew {
+				t.Errorf("new: expected %s, got %s", tt.wantNew, got)
+			}
+		})
+	}
+}
+
+func TestEmphasizeRestoresAfterReset(t *testing.T) {
+	// Shaped like chroma output: every token is followed by a reset
+	styled := "+\x1b[38;5;248mfoo\x1b[0m\x1b[38;5;147mbar\x1b[0m baz"
+	got := emphasize(styled, []Span{{Start: 0, End: 6}}, 1, "<on>", "<off>")
+
+	want := "+\x1b[38;5;248m<on>foo\x1b[0m<on>\x1b[38;5;147m<on>bar\x1b[0m<on><off> baz"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+// spanText returns the text covered by each span, for readable test failures
+func spanText(text string, spans []Span) string {
+	parts := make([]string, len(spans))
+	for i, s := range spans {
+		parts[i] = text[s.Start:s.End]
+	}
+	return fmt.Sprint(parts)
+}
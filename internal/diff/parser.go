@@ -0,0 +1,288 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Marker git emits after a line that isn't terminated by a newline
+const noNewlineMarker = `\ No newline at end of file`
+
+// Shown in place of the hunks of a combined diff, which can't be displayed
+const combinedDiffNotice = "Combined diff of a merge conflict can't be displayed, resolve the conflict to review the changes"
+
+// Regex to match hunk headers and extract the old and new ranges plus the section heading
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// Parse parses a unified diff, as produced by git diff, into one File per changed file
+func Parse(raw string) ([]*File, error) {
+	var files []*File
+	var file *File
+	var hunk *Hunk
+	var oldLine, newLine, oldLeft, newLeft int
+	inCombinedBody := false // Whether the hunks of a combined diff are being skipped
+
+	lines := strings.Split(raw, "\n")
+	// A trailing newline terminates the last line rather than starting a new one
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for i, line := range lines {
+		if file != nil && file.IsCombined && !strings.HasPrefix(line, "diff ") {
+			// Combined diffs aren't shown line by line, so only their headers are kept. Every
+			// hunk line starts with a column per parent, so none can be mistaken for a header.
+			if strings.HasPrefix(line, "@@") {
+				inCombinedBody = true
+			}
+			if !inCombinedBody {
+				file.Headers = append(file.Headers, line)
+				parseExtendedHeader(file, line)
+			}
+			continue
+		}
+
+		inHunk := hunk != nil && (oldLeft > 0 || newLeft > 0)
+
+		switch {
+		case inHunk && (line == "" || line[0] == ' ' || line[0] == '+' || line[0] == '-'):
+			// Some tools strip the trailing space from empty context lines
+			kind, content := KindContext, ""
+			if line != "" {
+				content = line[1:]
+				switch line[0] {
+				case '+':
+					kind = KindAdded
+				case '-':
+					kind = KindDeleted
+				}
+			}
+
+			l := &Line{Kind: kind, Content: content}
+			if kind != KindAdded {
+				l.OldLine = oldLine
+				oldLine++
+				oldLeft--
+			}
+			if kind != KindDeleted {
+				l.NewLine = newLine
+				newLine++
+				newLeft--
+			}
+			hunk.Lines = append(hunk.Lines, l)
+
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" applies to the preceding line
+			if hunk != nil && len(hunk.Lines) > 0 {
+				hunk.Lines[len(hunk.Lines)-1].NoNewline = true
+			}
+
+		case strings.HasPrefix(line, "@@"):
+			if file == nil {
+				return nil, fmt.Errorf("line %d: hunk header before file header", i+1)
+			}
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			hunk = h
+			file.Hunks = append(file.Hunks, hunk)
+			oldLine, oldLeft = hunk.OldStart, hunk.OldLines
+			newLine, newLeft = hunk.NewStart, hunk.NewLines
+
+		case strings.HasPrefix(line, "diff --git "):
+			file = &File{}
+			hunk = nil
+			file.OldName, file.NewName = parseGitHeaderNames(strings.TrimPrefix(line, "diff --git "))
+			file.Headers = append(file.Headers, line)
+			files = append(files, file)
+
+		case strings.HasPrefix(line, "diff --cc ") || strings.HasPrefix(line, "diff --combined "):
+			// Merge conflicts are diffed against every parent at once, e.g. "diff --cc f.txt"
+			_, name, _ := strings.Cut(strings.TrimPrefix(line, "diff --"), " ")
+			file = &File{IsCombined: true, OldName: unquotePath(name), NewName: unquotePath(name)}
+			hunk = nil
+			inCombinedBody = false
+			file.Headers = append(file.Headers, line)
+			files = append(files, file)
+
+		case strings.HasPrefix(line, "--- ") && (file == nil || len(file.Hunks) > 0):
+			// Plain unified diff without a "diff --git" line starts a new file
+			file = &File{}
+			hunk = nil
+			files = append(files, file)
+			file.Headers = append(file.Headers, line)
+			file.OldName = parseFileHeaderName(strings.TrimPrefix(line, "--- "), "a/")
+
+		default:
+			if file == nil {
+				return nil, fmt.Errorf("line %d: unexpected content before file header: %q", i+1, line)
+			}
+			file.Headers = append(file.Headers, line)
+			parseExtendedHeader(file, line)
+		}
+	}
+
+	for _, f := range files {
+		for _, h := range f.Hunks {
+			markIntraLineChanges(h)
+		}
+		if f.IsNew {
+			f.OldName = ""
+		}
+		if f.IsDeleted {
+			f.NewName = ""
+		}
+	}
+
+	return files, nil
+}
+
+// parseHunkHeader parses a "@@ -a,b +c,d @@ section" line
+func parseHunkHeader(line string) (*Hunk, error) {
+	matches := hunkHeaderRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, fmt.Errorf("malformed hunk header: %q", line)
+	}
+	return &Hunk{
+		Header:   line,
+		OldStart: atoi(matches[1]),
+		OldLines: atoiDefault(matches[2], 1),
+		NewStart: atoi(matches[3]),
+		NewLines: atoiDefault(matches[4], 1),
+		Section:  matches[5],
+	}, nil
+}
+
+// parseExtendedHeader records git's extended header lines (modes, renames, binary markers)
+func parseExtendedHeader(file *File, line string) {
+	switch {
+	case strings.HasPrefix(line, "--- "):
+		if name := parseFileHeaderName(strings.TrimPrefix(line, "--- "), "a/"); name != "" {
+			file.OldName = name
+		} else {
+			file.IsNew = true
+		}
+	case strings.HasPrefix(line, "+++ "):
+		if name := parseFileHeaderName(strings.TrimPrefix(line, "+++ "), "b/"); name != "" {
+			file.NewName = name
+		} else {
+			file.IsDeleted = true
+		}
+	case strings.HasPrefix(line, "new file mode "):
+		file.IsNew = true
+		file.NewMode = strings.TrimPrefix(line, "new file mode ")
+	case strings.HasPrefix(line, "deleted file mode "):
+		file.IsDeleted = true
+		file.OldMode = strings.TrimPrefix(line, "deleted file mode ")
+	case strings.HasPrefix(line, "old mode "):
+		file.OldMode = strings.TrimPrefix(line, "old mode ")
+	case strings.HasPrefix(line, "new mode "):
+		file.NewMode = strings.TrimPrefix(line, "new mode ")
+	case strings.HasPrefix(line, "index "):
+		// "index abc..def 100644" carries the mode when it didn't change
+		fields := strings.Fields(line)
+		if len(fields) == 3 && file.OldMode == "" && file.NewMode == "" {
+			file.OldMode, file.NewMode = fields[2], fields[2]
+		}
+	case strings.HasPrefix(line, "similarity index "):
+		file.Similarity = atoi(strings.TrimSuffix(strings.TrimPrefix(line, "similarity index "), "%"))
+	case strings.HasPrefix(line, "rename from "):
+		file.IsRename = true
+		file.OldName = unquotePath(strings.TrimPrefix(line, "rename from "))
+	case strings.HasPrefix(line, "rename to "):
+		file.IsRename = true
+		file.NewName = unquotePath(strings.TrimPrefix(line, "rename to "))
+	case strings.HasPrefix(line, "copy from "):
+		file.IsCopy = true
+		file.OldName = unquotePath(strings.TrimPrefix(line, "copy from "))
+	case strings.HasPrefix(line, "copy to "):
+		file.IsCopy = true
+		file.NewName = unquotePath(strings.TrimPrefix(line, "copy to "))
+	case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+		file.IsBinary = true
+	}
+}
+
+// parseGitHeaderNames extracts the old and new paths from the "a/x b/y" part of a diff --git line
+func parseGitHeaderNames(names string) (string, string) {
+	// Quoted paths are unambiguous
+	if strings.HasPrefix(names, `"`) {
+		oldName, rest, ok := cutQuoted(names)
+		if !ok {
+			return "", ""
+		}
+		return strings.TrimPrefix(oldName, "a/"), strings.TrimPrefix(unquotePath(strings.TrimPrefix(rest, " ")), "b/")
+	}
+	if i := strings.Index(names, ` "`); i >= 0 {
+		return strings.TrimPrefix(names[:i], "a/"), strings.TrimPrefix(unquotePath(names[i+1:]), "b/")
+	}
+
+	// Unquoted paths may contain spaces; when both sides are equal the split is in the middle
+	if len(names)%2 == 1 {
+		mid := len(names) / 2
+		oldName, newName := names[:mid], names[mid+1:]
+		if strings.HasPrefix(oldName, "a/") && strings.HasPrefix(newName, "b/") && oldName[2:] == newName[2:] {
+			return oldName[2:], newName[2:]
+		}
+	}
+
+	// Otherwise fall back to splitting on the new path prefix; ---/+++ and rename headers refine this
+	if i := strings.Index(names, " b/"); i >= 0 {
+		return strings.TrimPrefix(names[:i], "a/"), names[i+3:]
+	}
+	return "", ""
+}
+
+// parseFileHeaderName extracts the path from a ---/+++ line, returning "" for /dev/null
+func parseFileHeaderName(name, prefix string) string {
+	// Some tools append a tab and timestamp after the path
+	if i := strings.Index(name, "\t"); i >= 0 {
+		name = name[:i]
+	}
+	name = unquotePath(name)
+	if name == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(name, prefix)
+}
+
+// unquotePath decodes a path that git has C-quoted because it contains special characters
+func unquotePath(path string) string {
+	if !strings.HasPrefix(path, `"`) {
+		return path
+	}
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		return unquoted
+	}
+	return path
+}
+
+// cutQuoted splits a leading C-quoted string from the rest of s
+func cutQuoted(s string) (string, string, bool) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return unquotePath(s[:i+1]), s[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// atoi converts a hunk header number, returning 0 if it is not a valid integer
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// atoiDefault converts an optional hunk header count, which git omits when it is 1
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	return atoi(s)
+}
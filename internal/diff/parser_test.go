@@ -0,0 +1,290 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHunkLineNumbers(t *testing.T) {
+	raw := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -10,3 +10,4 @@ func main() {
+ 	a := 1
+-	b := 2
++	b := 3
++	c := 4
+ 	return
+@@ -40 +41 @@
+-old
++new
+\ No newline at end of file
+`
+
+	files, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+
+	f := files[0]
+	if f.OldName != "main.go" || f.NewName != "main.go" {
+		t.Errorf("expected names main.go/main.go, got %q/%q", f.OldName, f.NewName)
+	}
+	if f.OldMode != "100644" || f.ModeChanged() {
+		t.Errorf("expected unchanged mode 100644, got %q -> %q", f.OldMode, f.NewMode)
+	}
+	if len(f.Hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(f.Hunks))
+	}
+
+	hunk := f.Hunks[0]
+	if hunk.OldStart != 10 || hunk.OldLines != 3 || hunk.NewStart != 10 || hunk.NewLines != 4 {
+		t.Errorf("unexpected hunk range: %+v", hunk)
+	}
+	if hunk.Section != "func main() {" {
+		t.Errorf("expected section heading, got %q", hunk.Section)
+	}
+
+	expected := []Line{
+		{Kind: KindContext, Content: "\ta := 1", OldLine: 10, NewLine: 10},
+		{Kind: KindDeleted, Content: "\tb := 2", OldLine: 11, Changed: []Span{{6, 7}}},
+		{Kind: KindAdded, Content: "\tb := 3", NewLine: 11, Changed: []Span{{6, 7}}},
+		{Kind: KindAdded, Content: "\tc := 4", NewLine: 12},
+		{Kind: KindContext, Content: "\treturn", OldLine: 12, NewLine: 13},
+	}
+	if len(hunk.Lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d", len(expected), len(hunk.Lines))
+	}
+	for i, want := range expected {
+		if !reflect.DeepEqual(*hunk.Lines[i], want) {
+			t.Errorf("line %d: expected %+v, got %+v", i, want, *hunk.Lines[i])
+		}
+	}
+
+	// Counts omitted from the header default to 1
+	second := f.Hunks[1]
+	if second.OldLines != 1 || second.NewLines != 1 {
+		t.Errorf("expected default counts of 1, got %d/%d", second.OldLines, second.NewLines)
+	}
+	if second.Lines[0].NoNewline || !second.Lines[1].NoNewline {
+		t.Errorf("expected only the added line to be marked as missing a newline")
+	}
+
+	// Rows include headers, hunk headers and the no-newline marker
+	rows := f.Rows()
+	if len(rows) != 4+1+5+1+2+1 {
+		t.Fatalf("unexpected row count %d", len(rows))
+	}
+	if rows[len(rows)-1].Kind != KindNoNewline {
+		t.Errorf("expected last row to be the no-newline marker, got %v", rows[len(rows)-1].Kind)
+	}
+	if rows[6].Text != "-\tb := 2" || rows[6].Number(SideOld) != 11 || rows[6].Number(SideNew) != 0 {
+		t.Errorf("unexpected deleted row: %+v", rows[6])
+	}
+}
+
+func TestParseFileMetadata(t *testing.T) {
+	tests := []struct {
+		name  string
+		raw   string
+		check func(t *testing.T, f *File)
+	}{
+		{
+			name: "new file",
+			raw: `diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..e69de29
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++hello
+`,
+			check: func(t *testing.T, f *File) {
+				if !f.IsNew || f.OldName != "" || f.NewName != "new.txt" || f.NewMode != "100644" {
+					t.Errorf("unexpected new file: %+v", f)
+				}
+			},
+		},
+		{
+			name: "deleted file",
+			raw: `diff --git a/old.txt b/old.txt
+deleted file mode 100755
+index e69de29..0000000
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+`,
+			check: func(t *testing.T, f *File) {
+				if !f.IsDeleted || f.NewName != "" || f.Name() != "old.txt" || f.OldMode != "100755" {
+					t.Errorf("unexpected deleted file: %+v", f)
+				}
+			},
+		},
+		{
+			name: "mode change",
+			raw: `diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`,
+			check: func(t *testing.T, f *File) {
+				if !f.ModeChanged() || f.OldMode != "100644" || f.NewMode != "100755" || len(f.Hunks) != 0 {
+					t.Errorf("unexpected mode change: %+v", f)
+				}
+			},
+		},
+		{
+			name: "rename",
+			raw: `diff --git a/old name.go b/new name.go
+similarity index 92%
+rename from old name.go
+rename to new name.go
+`,
+			check: func(t *testing.T, f *File) {
+				if !f.IsRename || f.Similarity != 92 || f.OldName != "old name.go" || f.NewName != "new name.go" {
+					t.Errorf("unexpected rename: %+v", f)
+				}
+			},
+		},
+		{
+			name: "copy",
+			raw: `diff --git a/a.go b/b.go
+similarity index 100%
+copy from a.go
+copy to b.go
+`,
+			check: func(t *testing.T, f *File) {
+				if !f.IsCopy || f.OldName != "a.go" || f.NewName != "b.go" {
+					t.Errorf("unexpected copy: %+v", f)
+				}
+			},
+		},
+		{
+			name: "binary",
+			raw: `diff --git a/logo.png b/logo.png
+index 1111111..2222222 100644
+Binary files a/logo.png and b/logo.png differ
+`,
+			check: func(t *testing.T, f *File) {
+				if !f.IsBinary || len(f.Hunks) != 0 || len(f.Rows()) != 3 {
+					t.Errorf("unexpected binary file: %+v", f)
+				}
+			},
+		},
+		{
+			name: "quoted unicode path",
+			raw: `diff --git "a/caf\303\251 menu.txt" "b/caf\303\251 menu.txt"
+index 1111111..2222222 100644
+--- "a/caf\303\251 menu.txt"
++++ "b/caf\303\251 menu.txt"
+@@ -1 +1 @@
+-tea
++coffee
+`,
+			check: func(t *testing.T, f *File) {
+				if f.OldName != "café menu.txt" || f.NewName != "café menu.txt" {
+					t.Errorf("expected unquoted names, got %q/%q", f.OldName, f.NewName)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := Parse(tt.raw)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("expected 1 file, got %d", len(files))
+			}
+			tt.check(t, files[0])
+		})
+	}
+}
+
+func TestParseMultipleFilesAndErrors(t *testing.T) {
+	raw := `diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1 +1 @@
+-a
++b
+diff --git a/b.go b/b.go
+--- a/b.go
++++ b/b.go
+@@ -5,2 +5,2 @@
+ x
+-y
++z
+`
+	files, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 2 || files[0].Name() != "a.go" || files[1].Name() != "b.go" {
+		t.Fatalf("expected files a.go and b.go, got %d files", len(files))
+	}
+	if rows := Rows(files); len(rows) != len(files[0].Rows())+len(files[1].Rows()) {
+		t.Errorf("expected Rows to concatenate each file's rows")
+	}
+
+	if files, err := Parse(""); err != nil || len(files) != 0 {
+		t.Errorf("expected empty diff to parse to no files, got %d files, err %v", len(files), err)
+	}
+
+	if _, err := Parse("diff --git a/a.go b/a.go\n@@ -x +1 @@\n"); err == nil {
+		t.Errorf("expected error for malformed hunk header")
+	}
+
+	if _, err := Parse("@@ -1 +1 @@\n-a\n+b\n"); err == nil {
+		t.Errorf("expected error for hunk without a file header")
+	}
+}
+
+func TestParseCombinedDiff(t *testing.T) {
+	// Output of git diff for a conflicted merge, followed by an ordinary file
+	raw := `diff --cc f.txt
+index f04eb26,ddc897f..0000000
+--- a/f.txt
++++ b/f.txt
+@@@ -1,3 -1,3 +1,7 @@@
+  one
+++<<<<<<< HEAD
+ +2
+++=======
++ TWO
+++>>>>>>> other
+  three
+diff --git a/a.go b/a.go
+--- a/a.go
++++ b/a.go
+@@ -1 +1 @@
+-a
++b
+`
+	files, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(files))
+	}
+
+	combined := files[0]
+	if !combined.IsCombined || combined.Name() != "f.txt" || len(combined.Hunks) != 0 {
+		t.Errorf("unexpected combined file: %+v", combined)
+	}
+	rows := combined.Rows()
+	if len(rows) != 5 || rows[4].Text != combinedDiffNotice {
+		t.Errorf("expected the headers and a notice, got %+v", rows)
+	}
+
+	if files[1].IsCombined || files[1].Name() != "a.go" || len(files[1].Hunks) != 1 {
+		t.Errorf("expected the next file to be parsed normally, got %+v", files[1])
+	}
+}
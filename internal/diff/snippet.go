@@ -0,0 +1,46 @@
+package diff
+
+// Snippet returns the lines start to end on one side of a diff along with up to context
+// lines on each side of them from the same hunk. With markers, the lines keep their +/-
+// prefix and deleted or added lines in between are included, so the snippet reads as a
+// diff; without, only the lines that exist on the side are returned, so it reads as code.
+// It returns nil if none of the lines are shown.
+func Snippet(rows []Row, side Side, start, end, context int, markers bool) []string {
+	var shown []Row
+	for _, row := range rows {
+		if row.Line != nil && (markers || row.Number(side) != 0) {
+			shown = append(shown, row)
+		}
+	}
+
+	first, last := -1, -1
+	for i, row := range shown {
+		if n := row.Number(side); n >= start && n <= end {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+
+	// Context stops at the edge of the hunk, since the lines past it aren't next to these
+	for i := 0; i < context && first > 0 && shown[first-1].Hunk == shown[first].Hunk; i++ {
+		first--
+	}
+	for i := 0; i < context && last < len(shown)-1 && shown[last+1].Hunk == shown[last].Hunk; i++ {
+		last++
+	}
+
+	var lines []string
+	for _, row := range shown[first : last+1] {
+		if markers {
+			lines = append(lines, row.Text)
+		} else {
+			lines = append(lines, row.Line.Content)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,60 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnippet(t *testing.T) {
+	raw := "--- a/f.go\n+++ b/f.go\n" +
+		"@@ -1,5 +1,5 @@\n a := 1\n b := 2\n-c := 3\n+c := 4\n d := 5\n e := 6\n" +
+		"@@ -20,2 +20,2 @@\n x := 1\n y := 2\n"
+	files, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	rows := Rows(files)
+
+	tests := []struct {
+		name       string
+		side       Side
+		start, end int
+		context    int
+		markers    bool
+		want       []string
+	}{
+		{
+			name: "changed line",
+			side: SideNew, start: 3, end: 3,
+			want: []string{"c := 4"},
+		},
+		{
+			name: "context leaves out the other side",
+			side: SideNew, start: 3, end: 3, context: 1,
+			want: []string{"b := 2", "c := 4", "d := 5"},
+		},
+		{
+			name: "markers keep the diff",
+			side: SideNew, start: 2, end: 3, markers: true,
+			want: []string{" b := 2", "-c := 3", "+c := 4"},
+		},
+		{
+			name: "context stops at the hunk",
+			side: SideOld, start: 5, end: 5, context: 3,
+			want: []string{"b := 2", "c := 3", "d := 5", "e := 6"},
+		},
+		{
+			name: "lines not shown",
+			side: SideNew, start: 10, end: 12,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Snippet(rows, tt.side, tt.start, tt.end, tt.context, tt.markers)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Snippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
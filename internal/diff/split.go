@@ -0,0 +1,166 @@
+package diff
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// SplitSeparator is drawn between the old and new columns of the side-by-side view
+const SplitSeparator = " │ "
+
+// Minimum width of a side-by-side column, so narrow terminals still show some code
+const minSplitColumnWidth = 20
+
+// SplitRow is a row of the side-by-side view. Headers and hunk headers span both
+// columns; content rows pair an old line (Left) with a new line (Right).
+type SplitRow struct {
+	Kind  LineKind // KindHeader or KindHunk for full width rows, otherwise KindContext
+	Text  string   // Raw text of full width rows
+	Hunk  *Hunk    // Hunk the row belongs to, nil for file headers
+	Left  *Row     // Old side, nil when the new line has no old counterpart
+	Right *Row     // New side, nil when the old line has no new counterpart
+}
+
+// Number returns the line number of the row on the given side, or 0 if that side is empty
+func (r SplitRow) Number(side Side) int {
+	if side == SideOld && r.Left != nil {
+		return r.Left.OldLine
+	}
+	if side == SideNew && r.Right != nil {
+		return r.Right.NewLine
+	}
+	return 0
+}
+
+// Side returns the row shown on the given side, or nil if that side is empty
+func (r SplitRow) Side(side Side) *Row {
+	if side == SideOld {
+		return r.Left
+	}
+	return r.Right
+}
+
+// rows returns the unified rows shown on either side of the row
+func (r SplitRow) rows() []Row {
+	var rows []Row
+	for _, row := range []*Row{r.Left, r.Right} {
+		if row != nil {
+			rows = append(rows, *row)
+		}
+	}
+	return rows
+}
+
+// SplitRows pairs unified rows for the side-by-side view. Context lines appear on both
+// sides; within each block of changes, deletions are aligned with the additions that follow them.
+func SplitRows(rows []Row) []SplitRow {
+	var split []SplitRow
+	var deleted, added []*Row
+
+	// flush pairs up the pending block of deletions and additions
+	flush := func() {
+		for i := 0; i < len(deleted) || i < len(added); i++ {
+			row := SplitRow{Kind: KindContext}
+			if i < len(deleted) {
+				row.Left = deleted[i]
+				row.Hunk = deleted[i].Hunk
+			}
+			if i < len(added) {
+				row.Right = added[i]
+				row.Hunk = added[i].Hunk
+			}
+			split = append(split, row)
+		}
+		deleted, added = nil, nil
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		switch row.Kind {
+		case KindDeleted:
+			// A deletion after additions starts a new block
+			if len(added) > 0 {
+				flush()
+			}
+			deleted = append(deleted, row)
+		case KindAdded:
+			added = append(added, row)
+		case KindNoNewline:
+			// Markers would break up deletion/addition pairs, so they're only shown in the unified view
+		case KindContext:
+			flush()
+			split = append(split, SplitRow{Kind: KindContext, Hunk: row.Hunk, Left: row, Right: row})
+		default:
+			flush()
+			split = append(split, SplitRow{Kind: row.Kind, Text: row.Text, Hunk: row.Hunk})
+		}
+	}
+	flush()
+
+	return split
+}
+
+// SplitColumnWidth returns the width of each side-by-side column for a terminal width
+func SplitColumnWidth(width int) int {
+	return max((width-lipgloss.Width(SplitSeparator))/2, minSplitColumnWidth)
+}
+
+// RenderSplit renders side-by-side rows, producing exactly one output line per row
+func RenderSplit(width int, rows []SplitRow, logger *slog.Logger) string {
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columnWidth := SplitColumnWidth(width)
+	digits := 1
+	for _, row := range rows {
+		digits = max(digits, LineNumberDigits(row.rows()))
+	}
+	formatted := make([]string, 0, len(rows))
+
+	for _, row := range rows {
+		switch row.Kind {
+		case KindHeader:
+			formatted = append(formatted, headerStyle.Width(width).MaxHeight(1).Render(row.Text))
+		case KindHunk:
+			formatted = append(formatted, hunkStyle.Render(row.Text))
+		default:
+			formatted = append(formatted, RenderSplitCell(columnWidth, digits, row.Left, SideOld, logger)+
+				separatorStyle.Render(SplitSeparator)+
+				RenderSplitCell(columnWidth, digits, row.Right, SideNew, logger))
+		}
+	}
+
+	return strings.Join(formatted, "\n")
+}
+
+// RenderSplitCell renders one side of a side-by-side row, with its line number in a gutter
+// of the given number of digits, truncated and padded to the column width
+func RenderSplitCell(columnWidth, digits int, row *Row, side Side, logger *slog.Logger) string {
+	if row == nil {
+		return emptyCellStyle.Render(strings.Repeat("╱", columnWidth))
+	}
+
+	code := row.Line.Content
+
+	var cell string
+	switch row.Kind {
+	case KindAdded:
+		cell = highlightLine(getHighlighter(), row.File.Name(), code, "+", additionStyle, logger)
+		cell = emphasize(cell, row.Line.Changed, 1, addedEmphasis, emphasisOff)
+	case KindDeleted:
+		cell = highlightLine(getHighlighter(), row.File.Name(), code, "-", deletionStyle, logger)
+		cell = emphasize(cell, row.Line.Changed, 1, deletedEmphasis, emphasisOff)
+	default:
+		cell = highlightLine(getHighlighter(), row.File.Name(), code, " ", lipgloss.NewStyle(), logger)
+	}
+
+	// Tabs would be expanded by the terminal and break the column alignment. They're
+	// replaced after highlighting so the changed word offsets still apply.
+	cell = splitGutter(digits, row.Number(side)) + strings.ReplaceAll(cell, "\t", "    ")
+	cell = ansi.Truncate(cell, columnWidth, "…")
+	return cell + strings.Repeat(" ", columnWidth-ansi.StringWidth(cell))
+}
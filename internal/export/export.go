@@ -0,0 +1,104 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/samverrall/review-ui/internal/diff"
+	"github.com/samverrall/review-ui/internal/git"
+)
+
+// Format names an export format, e.g. "json"
+type Format string
+
+const (
+	FormatMarkdown Format = "markdown" // Markdown for pasting into a coding agent or pull request
+	FormatJSON     Format = "json"     // Versioned JSON for scripts and agent harnesses
+	FormatSARIF    Format = "sarif"    // SARIF 2.1.0 log for CI dashboards and IDEs
+)
+
+// Formats lists the supported formats, the default first
+var Formats = []Format{FormatMarkdown, FormatJSON, FormatSARIF}
+
+// Exporter writes a review in some format
+type Exporter interface {
+	Export(w io.Writer, review Review) error
+	Extension() string // File extension of exported reviews, e.g. ".md"
+}
+
+// Options configures exporters
+type Options struct {
+	// SeverityGroups lists the severities markdown comments are grouped by, in order, with ""
+	// for untagged comments. Comments are only grouped by file when it's empty.
+	SeverityGroups []string
+}
+
+// New returns the exporter for a format, defaulting to markdown when the format is ""
+func New(format Format, opts Options) (Exporter, error) {
+	switch format {
+	case FormatMarkdown, "":
+		return Markdown{SeverityGroups: opts.SeverityGroups}, nil
+	case FormatJSON:
+		return JSON{}, nil
+	case FormatSARIF:
+		return SARIF{}, nil
+	}
+
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return nil, fmt.Errorf("unknown export format %q, expected one of %s", format, strings.Join(names, ", "))
+}
+
+// Review is a review ready to be exported
+type Review struct {
+	Mode      git.DiffMode // Which changes were reviewed
+	Commit    string       // Commit SHA of the reviewed revision, or of HEAD for changes on it, "" before the first commit
+	Generated time.Time    // When the review was exported
+	Summary   string       // Overall review summary, "" if none was written
+	Comments  []Comment    // Comments starting open threads, in file and line order
+}
+
+// IsEmpty reports whether there's nothing to export
+func (r Review) IsEmpty() bool {
+	return r.Summary == "" && len(r.Comments) == 0
+}
+
+// Comment is an exported comment along with the replies to it
+type Comment struct {
+	ID        int
+	File      string
+	Side      diff.Side // Side the line numbers refer to
+	StartLine int       // First commented line, 0 for notes on the whole file
+	EndLine   int       // Last commented line, 0 for notes on the whole file
+	Severity  string    // Category such as "blocking" or "nit", "" if untagged
+	Body      string
+	Author    string
+	CreatedAt time.Time
+	Outdated  bool     // Whether the commented code is no longer in the diff
+	Code      []string // Commented code, nil if it's not exported or not available
+	Lines     []string // Commented lines alone, without context or markers, nil along with Code
+	Language  string   // Language of the code for syntax highlighting, e.g. "go", "" if unknown
+	Replies   []Reply
+}
+
+// IsFileLevel reports whether the comment is a note on the whole file rather than some lines
+func (c Comment) IsFileLevel() bool {
+	return c.StartLine == 0
+}
+
+// sameLines reports whether two comments are on the same lines of the same file
+func (c Comment) sameLines(other Comment) bool {
+	return c.File == other.File && c.Side == other.Side && c.StartLine == other.StartLine && c.EndLine == other.EndLine
+}
+
+// Reply is an exported reply to a comment
+type Reply struct {
+	ID        int
+	Body      string
+	Author    string
+	CreatedAt time.Time
+}
@@ -0,0 +1,138 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samverrall/review-ui/internal/diff"
+	"github.com/samverrall/review-ui/internal/git"
+)
+
+// sampleReview is a review with a summary, a file note and a thread on some lines
+func sampleReview() Review {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return Review{
+		Mode:      git.Commit("feature"),
+		Commit:    "0123456789abcdef0123456789abcdef01234567",
+		Generated: created,
+		Summary:   "Looks good overall",
+		Comments: []Comment{
+			{ID: 1, File: "main.go", Severity: "nit", Body: "Split this file"},
+			{
+				ID: 2, File: "main.go", Side: diff.SideNew, StartLine: 11, EndLine: 12,
+				Severity: "blocking", Body: "c is never used", Author: "alice", CreatedAt: created,
+				Code: []string{"\tb := 3", "\tc := 4"}, Lines: []string{"\tb := 3", "\tc := 4"}, Language: "go",
+				Replies: []Reply{{ID: 3, Body: "Fixed", Author: "bob", CreatedAt: created}},
+			},
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, format := range append(Formats, "") {
+		if _, err := New(format, Options{}); err != nil {
+			t.Errorf("expected %q to be supported, got %v", format, err)
+		}
+	}
+	if _, err := New("yaml", Options{}); err == nil || !strings.Contains(err.Error(), "markdown, json") {
+		t.Errorf("expected unknown format to list the supported ones, got %v", err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var b strings.Builder
+	if err := (JSON{}).Export(&b, sampleReview()); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	want := `{
+  "version": 1,
+  "mode": {
+    "kind": "commit",
+    "commit": "feature"
+  },
+  "commit": "0123456789abcdef0123456789abcdef01234567",
+  "generated_at": "2026-01-02T03:04:05Z",
+  "summary": "Looks good overall",
+  "comments": [
+    {
+      "id": 1,
+      "file": "main.go",
+      "start_line": 0,
+      "end_line": 0,
+      "severity": "nit",
+      "body": "Split this file"
+    },
+    {
+      "id": 2,
+      "file": "main.go",
+      "side": "new",
+      "start_line": 11,
+      "end_line": 12,
+      "severity": "blocking",
+      "body": "c is never used",
+      "author": "alice",
+      "created_at": "2026-01-02T03:04:05Z",
+      "snippet": "\tb := 3\n\tc := 4",
+      "language": "go",
+      "replies": [
+        {
+          "id": 3,
+          "body": "Fixed",
+          "author": "bob",
+          "created_at": "2026-01-02T03:04:05Z"
+        }
+      ]
+    }
+  ]
+}
+`
+	if b.String() != want {
+		t.Errorf("unexpected JSON:\n%s", b.String())
+	}
+
+	// An empty review still has a list of comments, so consumers needn't check for null
+	b.Reset()
+	if err := (JSON{}).Export(&b, Review{}); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if !strings.Contains(b.String(), `"comments": []`) {
+		t.Errorf("expected an empty list of comments, got:\n%s", b.String())
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	var b strings.Builder
+	if err := (Markdown{}).Export(&b, sampleReview()); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	want := "# Code Review Comments\n# Generated: 2026-01-02 03:04:05\n\n" +
+		"## Summary\n\nLooks good overall\n\n" +
+		"## File: main.go\n\n- **nit:** Split this file\n\n" +
+		"### Lines 11-12 (new)\n```go\n\tb := 3\n\tc := 4\n```\n- **blocking:** c is never used\n  - bob: Fixed\n\n"
+	if b.String() != want {
+		t.Errorf("unexpected markdown:\n%q\nwant:\n%q", b.String(), want)
+	}
+
+	// A body starting with a fence gets the label on its own line, so the fence still opens a block
+	b.Reset()
+	suggestion := Review{Comments: []Comment{{ID: 1, File: "main.go", Side: diff.SideNew, StartLine: 3, EndLine: 3, Severity: "suggestion", Body: "```suggestion\n\tc := 4\n```"}}}
+	if err := (Markdown{}).Export(&b, suggestion); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if want := "- **suggestion:**\n  ```suggestion\n  \tc := 4\n  ```\n"; !strings.Contains(b.String(), want) {
+		t.Errorf("expected fence on its own line, got:\n%q", b.String())
+	}
+
+	// Grouping by severity puts each group under its own heading, in the given order
+	b.Reset()
+	if err := (Markdown{SeverityGroups: []string{"blocking", "nit", ""}}).Export(&b, sampleReview()); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	blocking, nit := strings.Index(b.String(), "## Blocking\n\n### File: main.go\n\n#### Lines 11-12 (new)\n"), strings.Index(b.String(), "## Nit\n\n### File: main.go\n\n- **nit:**")
+	if blocking < 0 || nit < blocking || strings.Contains(b.String(), "## Untagged") {
+		t.Errorf("expected blocking then nit groups, got:\n%s", b.String())
+	}
+}
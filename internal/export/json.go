@@ -0,0 +1,92 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/samverrall/review-ui/internal/git"
+)
+
+// JSONVersion is the version of the JSON export schema. Fields may be added without
+// bumping it, but it's bumped whenever a field is removed, renamed or changes meaning.
+const JSONVersion = 1
+
+// JSON exports a review as a versioned JSON document for scripts and agent harnesses
+type JSON struct{}
+
+// jsonReview is the top level of the JSON schema. It's kept separate from Review so the
+// schema only changes on purpose.
+type jsonReview struct {
+	Version   int           `json:"version"`
+	Mode      git.DiffMode  `json:"mode"`   // Which changes were reviewed, encoded as in saved sessions
+	Commit    string        `json:"commit"` // Commit SHA of the reviewed revision, or of HEAD for changes on it
+	Generated time.Time     `json:"generated_at"`
+	Summary   string        `json:"summary,omitempty"`
+	Comments  []jsonComment `json:"comments"`
+}
+
+type jsonComment struct {
+	ID        int         `json:"id"`
+	File      string      `json:"file"`
+	Side      string      `json:"side,omitempty"` // "old" or "new", omitted for notes on the whole file
+	StartLine int         `json:"start_line"`     // 0 for notes on the whole file
+	EndLine   int         `json:"end_line"`       // 0 for notes on the whole file
+	Severity  string      `json:"severity,omitempty"`
+	Body      string      `json:"body"`
+	Author    string      `json:"author,omitempty"`
+	CreatedAt time.Time   `json:"created_at,omitzero"`
+	Outdated  bool        `json:"outdated,omitempty"`
+	Snippet   string      `json:"snippet,omitempty"`  // Commented code, one line per line of the file
+	Language  string      `json:"language,omitempty"` // Language of the snippet, e.g. "go"
+	Replies   []jsonReply `json:"replies,omitempty"`
+}
+
+type jsonReply struct {
+	ID        int       `json:"id"`
+	Body      string    `json:"body"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// Extension returns the file extension of JSON exports
+func (JSON) Extension() string { return ".json" }
+
+// Export writes the review as indented JSON
+func (JSON) Export(w io.Writer, review Review) error {
+	doc := jsonReview{
+		Version:   JSONVersion,
+		Mode:      review.Mode,
+		Commit:    review.Commit,
+		Generated: review.Generated,
+		Summary:   review.Summary,
+		Comments:  []jsonComment{},
+	}
+	for _, c := range review.Comments {
+		jc := jsonComment{
+			ID:        c.ID,
+			File:      c.File,
+			StartLine: c.StartLine,
+			EndLine:   c.EndLine,
+			Severity:  c.Severity,
+			Body:      c.Body,
+			Author:    c.Author,
+			CreatedAt: c.CreatedAt,
+			Outdated:  c.Outdated,
+			Snippet:   strings.Join(c.Code, "\n"),
+			Language:  c.Language,
+		}
+		if !c.IsFileLevel() {
+			jc.Side = c.Side.String()
+		}
+		for _, r := range c.Replies {
+			jc.Replies = append(jc.Replies, jsonReply{ID: r.ID, Body: r.Body, Author: r.Author, CreatedAt: r.CreatedAt})
+		}
+		doc.Comments = append(doc.Comments, jc)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
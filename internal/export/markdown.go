@@ -0,0 +1,152 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Markdown exports a review as a markdown document, with the summary first and comments
+// under headings for their file and lines
+type Markdown struct {
+	SeverityGroups []string // Severities to group comments by, in order, "" for untagged
+}
+
+// Extension returns the file extension of markdown exports
+func (Markdown) Extension() string { return ".md" }
+
+// Export writes the review as markdown
+func (e Markdown) Export(w io.Writer, review Review) error {
+	var b strings.Builder
+	b.WriteString("# Code Review Comments\n")
+	b.WriteString(fmt.Sprintf("# Generated: %s\n\n", review.Generated.Format("2006-01-02 15:04:05")))
+
+	// The summary comes first, so the overall feedback is read before the details
+	if review.Summary != "" {
+		b.WriteString(fmt.Sprintf("## Summary\n\n%s\n\n", strings.TrimRight(review.Summary, "\n")))
+	}
+
+	if len(e.SeverityGroups) == 0 {
+		writeComments(&b, 2, review.Comments)
+	} else {
+		for _, sev := range e.SeverityGroups {
+			var group []Comment
+			for _, c := range review.Comments {
+				if c.Severity == sev {
+					group = append(group, c)
+				}
+			}
+			if len(group) > 0 {
+				b.WriteString(fmt.Sprintf("## %s\n\n", severityTitle(sev)))
+				writeComments(&b, 3, group)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeComments writes comments grouped by file then lines, with file headings at the given
+// level. Notes on a whole file come straight after its heading.
+func writeComments(b *strings.Builder, level int, comments []Comment) {
+	heading := strings.Repeat("#", level)
+	for i, c := range comments {
+		if i == 0 || !c.sameLines(comments[i-1]) {
+			// Add file header if we've moved to a new file
+			if i == 0 || c.File != comments[i-1].File {
+				if i > 0 {
+					b.WriteString("\n")
+				}
+				b.WriteString(fmt.Sprintf("%s File: %s\n\n", heading, c.File))
+			}
+
+			// Add a heading for the lines, e.g. "### Lines 12-14 (new)", and the code on them
+			if !c.IsFileLevel() {
+				b.WriteString(fmt.Sprintf("%s# %s\n", heading, linesLabel(c)))
+				b.WriteString(codeBlock(c.Code, c.Language))
+			}
+		}
+
+		body := c.Body
+		if c.Severity != "" {
+			// A fence only opens a code block at the start of a line, so it goes under the label
+			separator := " "
+			if strings.HasPrefix(body, "```") || strings.HasPrefix(body, "~~~") {
+				separator = "\n"
+			}
+			body = fmt.Sprintf("**%s:**%s%s", c.Severity, separator, body)
+		}
+		b.WriteString(listItem(body))
+
+		// Replies are nested under the comment that started the thread
+		for _, reply := range c.Replies {
+			body := reply.Body
+			if reply.Author != "" {
+				body = fmt.Sprintf("%s: %s", reply.Author, body)
+			}
+			for _, line := range strings.SplitAfter(listItem(body), "\n") {
+				if strings.TrimSpace(line) != "" {
+					line = "  " + line
+				}
+				b.WriteString(line)
+			}
+		}
+
+		if i == len(comments)-1 || !c.sameLines(comments[i+1]) {
+			b.WriteString("\n")
+		}
+	}
+}
+
+// linesLabel describes the lines a comment is on for headings, e.g. "Lines 12-14 (new)"
+func linesLabel(c Comment) string {
+	label := fmt.Sprintf("Line %d (%s)", c.StartLine, c.Side)
+	if c.EndLine != c.StartLine {
+		label = fmt.Sprintf("Lines %d-%d (%s)", c.StartLine, c.EndLine, c.Side)
+	}
+	if c.Outdated {
+		label += " (outdated)"
+	}
+	return label
+}
+
+// severityTitle returns a severity for headings, e.g. "Blocking"
+func severityTitle(sev string) string {
+	if sev == "" {
+		return "Untagged"
+	}
+	return strings.ToUpper(sev[:1]) + sev[1:]
+}
+
+// codeBlock formats code as a fenced block tagged with its language, or "" if there's no code
+func codeBlock(lines []string, language string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+
+	// Use a longer fence than any in the code, so the code can't end the block early
+	code := strings.Join(lines, "\n")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fmt.Sprintf("%s%s\n%s\n%s\n", fence, language, code, fence)
+}
+
+// listItem formats a comment as a markdown list item. Lines after the first are indented
+// so paragraphs, nested lists and code blocks in the comment stay part of the item.
+func listItem(body string) string {
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(body, "\r\n", "\n"), "\n"), "\n")
+
+	var b strings.Builder
+	b.WriteString("- " + lines[0] + "\n")
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString("  " + line + "\n")
+	}
+	return b.String()
+}
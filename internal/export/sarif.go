@@ -0,0 +1,200 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/samverrall/review-ui/internal/diff"
+)
+
+// SARIF exports a review as a SARIF 2.1.0 log, so review comments show up alongside linter
+// findings in CI dashboards and IDEs. Each comment becomes a result whose rule is its severity.
+type SARIF struct{}
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifSrcRoot = "%SRCROOT%" // Base ID of file URIs, resolved by consumers to the repository root
+)
+
+// sarifLevels maps severities to SARIF result levels, untagged comments being notes
+var sarifLevels = map[string]string{
+	"blocking":   "error",
+	"suggestion": "warning",
+	"nit":        "note",
+	"question":   "note",
+	"praise":     "none",
+}
+
+// sarifRules describes the rule each severity is reported as, untagged comments being "comment"
+var sarifRules = map[string]string{
+	"blocking":   "Blocking review comment that must be addressed",
+	"suggestion": "Suggested change from code review",
+	"nit":        "Minor style or naming issue from code review",
+	"question":   "Question from code review",
+	"praise":     "Praise from code review",
+	"comment":    "Code review comment",
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool      `json:"tool"`
+	Results    []sarifResult  `json:"results"`
+	Properties map[string]any `json:"properties,omitempty"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties map[string]any  `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int           `json:"startLine"`
+	EndLine   int           `json:"endLine"`
+	Snippet   *sarifMessage `json:"snippet,omitempty"`
+}
+
+// Extension returns the file extension of SARIF exports
+func (SARIF) Extension() string { return ".sarif" }
+
+// Export writes the review as a SARIF log with a single run. Comments on lines of the new
+// version of a file get a region; notes on whole files, comments on old lines and
+// outdated comments only point at the file, since their line numbers don't apply to it.
+func (SARIF) Export(w io.Writer, review Review) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "review-ui",
+			InformationURI: "https://github.com/samverrall/review-ui",
+		}},
+		Results: []sarifResult{},
+	}
+	if review.Commit != "" || review.Summary != "" {
+		run.Properties = make(map[string]any)
+		if review.Commit != "" {
+			run.Properties["commit"] = review.Commit
+		}
+		if review.Summary != "" {
+			run.Properties["summary"] = review.Summary
+		}
+	}
+
+	ruleIDs := make(map[string]bool)
+	for _, c := range review.Comments {
+		result := sarifResult{
+			RuleID:  sarifRuleID(c.Severity),
+			Level:   sarifLevel(c.Severity),
+			Message: sarifMessage{Text: sarifText(c)},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: (&url.URL{Path: c.File}).String(), URIBaseID: sarifSrcRoot},
+			}}},
+		}
+		switch {
+		case c.IsFileLevel():
+			// Notes on the whole file have no lines
+		case c.Side == diff.SideOld || c.Outdated:
+			result.Properties = map[string]any{"side": c.Side.String(), "startLine": c.StartLine, "endLine": c.EndLine, "outdated": c.Outdated}
+		default:
+			region := &sarifRegion{StartLine: c.StartLine, EndLine: c.EndLine}
+			if len(c.Lines) > 0 {
+				// The snippet is the region's own lines, so the code's context and markers are left out
+				region.Snippet = &sarifMessage{Text: strings.Join(c.Lines, "\n")}
+			}
+			result.Locations[0].PhysicalLocation.Region = region
+		}
+		run.Results = append(run.Results, result)
+
+		// Rules are listed in the order they're first used
+		if !ruleIDs[result.RuleID] {
+			ruleIDs[result.RuleID] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               result.RuleID,
+				ShortDescription: sarifMessage{Text: sarifRules[result.RuleID]},
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
+
+// sarifRuleID returns the rule a comment is reported as, its severity or "comment" if untagged
+func sarifRuleID(severity string) string {
+	if _, known := sarifLevels[severity]; !known {
+		return "comment"
+	}
+	return severity
+}
+
+// sarifLevel returns the result level of a severity
+func sarifLevel(severity string) string {
+	if level, ok := sarifLevels[severity]; ok {
+		return level
+	}
+	return "note"
+}
+
+// sarifText returns the message of a comment's result, with any replies after it
+func sarifText(c Comment) string {
+	text := c.Body
+	if c.Side == diff.SideOld && !c.IsFileLevel() {
+		// The result only points at the file, so say which of the old lines it's on
+		if c.EndLine != c.StartLine {
+			text += fmt.Sprintf("\n\n(On old lines %d-%d)", c.StartLine, c.EndLine)
+		} else {
+			text += fmt.Sprintf("\n\n(On old line %d)", c.StartLine)
+		}
+	}
+	for _, reply := range c.Replies {
+		if reply.Author != "" {
+			text += fmt.Sprintf("\n\n%s: %s", reply.Author, reply.Body)
+		} else {
+			text += "\n\n" + reply.Body
+		}
+	}
+	return text
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/samverrall/review-ui/internal/diff"
+)
+
+func TestSARIF(t *testing.T) {
+	review := sampleReview()
+	// The code comes with context and markers, which the region's snippet leaves out
+	review.Comments[1].Code = []string{" \ta := 1", "+\tb := 3", "+\tc := 4", " }"}
+	review.Comments = append(review.Comments,
+		Comment{ID: 4, File: "cmd/old main.go", Side: diff.SideOld, StartLine: 3, EndLine: 3, Body: "Why was this removed?", Severity: "question"},
+		Comment{ID: 5, File: "main.go", Side: diff.SideNew, StartLine: 20, EndLine: 20, Body: "Nice"},
+	)
+
+	var b strings.Builder
+	if err := (SARIF{}).Export(&b, review); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string `json:"name"`
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID  string `json:"ruleId"`
+				Level   string `json:"level"`
+				Message struct {
+					Text string `json:"text"`
+				} `json:"message"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI       string `json:"uri"`
+							URIBaseID string `json:"uriBaseId"`
+						} `json:"artifactLocation"`
+						Region *struct {
+							StartLine int `json:"startLine"`
+							EndLine   int `json:"endLine"`
+							Snippet   struct {
+								Text string `json:"text"`
+							} `json:"snippet"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+			Properties map[string]string `json:"properties"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal([]byte(b.String()), &log); err != nil {
+		t.Fatalf("expected valid JSON, got %v:\n%s", err, b.String())
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("expected a single SARIF 2.1.0 run, got:\n%s", b.String())
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "review-ui" || run.Properties["commit"] != review.Commit {
+		t.Errorf("expected review-ui driver and the commit, got:\n%s", b.String())
+	}
+	var rules []string
+	for _, rule := range run.Tool.Driver.Rules {
+		rules = append(rules, rule.ID)
+	}
+	if strings.Join(rules, ",") != "nit,blocking,question,comment" {
+		t.Errorf("expected a rule per severity used, got %v", rules)
+	}
+	if len(run.Results) != 4 {
+		t.Fatalf("expected a result per comment, got %d", len(run.Results))
+	}
+
+	// Notes on the whole file point at the file without a region
+	note := run.Results[0]
+	if loc := note.Locations[0].PhysicalLocation; note.Level != "note" || loc.ArtifactLocation.URI != "main.go" || loc.ArtifactLocation.URIBaseID != "%SRCROOT%" || loc.Region != nil {
+		t.Errorf("unexpected file note result: %+v", note)
+	}
+
+	// Comments on lines get a region with their line numbers and code, and replies in the message
+	blocking := run.Results[1]
+	region := blocking.Locations[0].PhysicalLocation.Region
+	if blocking.Level != "error" || region == nil || region.StartLine != 11 || region.EndLine != 12 || region.Snippet.Text != "\tb := 3\n\tc := 4" {
+		t.Errorf("unexpected blocking result: %+v", blocking)
+	}
+	if blocking.Message.Text != "c is never used\n\nbob: Fixed" {
+		t.Errorf("expected the reply in the message, got %q", blocking.Message.Text)
+	}
+
+	// Old lines aren't in the file, so only the file is pointed at
+	old := run.Results[2]
+	if loc := old.Locations[0].PhysicalLocation; loc.Region != nil || loc.ArtifactLocation.URI != "cmd/old%20main.go" {
+		t.Errorf("unexpected old lines result: %+v", old)
+	}
+	if old.Message.Text != "Why was this removed?\n\n(On old line 3)" {
+		t.Errorf("expected the old line in the message, got %q", old.Message.Text)
+	}
+
+	if untagged := run.Results[3]; untagged.RuleID != "comment" || untagged.Level != "note" {
+		t.Errorf("expected untagged comment as a note, got %+v", untagged)
+	}
+}
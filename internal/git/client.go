@@ -11,8 +11,11 @@ import (
 // GitClient defines the interface for git operations
 type GitClient interface {
 	IsGitRepo() (bool, error)
-	GetChangedFiles() ([]string, error)
-	GetFileDiff(filename string) (string, error)
+	GetChangedFiles(mode DiffMode) ([]ChangedFile, error)
+	GetFileDiff(mode DiffMode, file ChangedFile) (string, error)
+	GetRepoInfo() (RepoInfo, error)
+	GetFingerprint(mode DiffMode) (string, error)
+	ResolveRevision(rev string) (string, error)
 }
 
 // IsGitRepo checks if the current directory is inside a git repository
@@ -27,140 +30,25 @@ func IsGitRepo() (bool, error) {
 	return true, nil
 }
 
-// GetChangedFiles returns a list of unstaged changed files and untracked files
-func GetChangedFiles() ([]string, error) {
-	// Get modified/staged files from git status
-	statusFiles, err := getStatusFiles()
-	if err != nil {
-		return nil, err
+// GetChangedFiles returns the files changed in the given mode. In working tree mode
+// this is every file reported by git status, including untracked files.
+func GetChangedFiles(mode DiffMode) ([]ChangedFile, error) {
+	if mode.Kind != ModeWorkingTree {
+		return getModeFiles(mode)
 	}
-
-	// Get untracked files from git ls-files
-	untrackedFiles, err := getUntrackedFiles()
-	if err != nil {
-		return nil, err
-	}
-
-	// Combine and deduplicate
-	allFiles := make(map[string]bool)
-	for _, file := range statusFiles {
-		allFiles[file] = true
-	}
-	for _, file := range untrackedFiles {
-		allFiles[file] = true
-	}
-
-	// Convert map to slice
-	var files []string
-	for file := range allFiles {
-		files = append(files, file)
-	}
-
-	return files, nil
-}
-
-// getStatusFiles returns modified/staged files from git status --porcelain
-func getStatusFiles() ([]string, error) {
-	cmd := exec.Command("git", "status", "--porcelain")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to get status files: %w", err)
-	}
-
-	output := strings.TrimSpace(out.String())
-	if output == "" {
-		return []string{}, nil
-	}
-
-	lines := strings.Split(output, "\n")
-	var files []string
-	for _, line := range lines {
-		if len(line) < 3 {
-			continue
-		}
-		// Trim leading whitespace
-		line = strings.TrimLeft(line, " \t")
-		if len(line) < 3 {
-			continue
-		}
-		// Skip status codes (first 1-2 characters) and find the filename
-		// Status codes are typically 1-2 characters (XY format)
-		var filenameStart int
-		if len(line) > 1 && line[1] == ' ' {
-			// XY file format (e.g., "M file")
-			filenameStart = 2
-		} else if len(line) > 2 && line[2] == ' ' {
-			// XYY file format (though rare, being safe)
-			filenameStart = 3
-		} else {
-			// Fallback: find first space
-			spaceIndex := strings.Index(line, " ")
-			if spaceIndex == -1 {
-				continue
-			}
-			filenameStart = spaceIndex + 1
-		}
-
-		if filenameStart >= len(line) {
-			continue
-		}
-
-		filename := strings.TrimSpace(line[filenameStart:])
-		if filename != "" && !strings.HasSuffix(filename, "/") {
-			// Skip directories (entries ending with /)
-			files = append(files, filename)
-		}
-	}
-
-	return files, nil
-}
-
-// getUntrackedFiles returns untracked files from git ls-files --others
-func getUntrackedFiles() ([]string, error) {
-	cmd := exec.Command("git", "ls-files", "--others", "--exclude-standard")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to get untracked files: %w", err)
-	}
-
-	output := strings.TrimSpace(out.String())
-	if output == "" {
-		return []string{}, nil
-	}
-
-	return strings.Split(output, "\n"), nil
-}
-
-// isFileTracked checks if a file is tracked by git
-func isFileTracked(filename string) (bool, error) {
-	cmd := exec.Command("git", "ls-files", "--error-unmatch", filename)
-	err := cmd.Run()
-	if err == nil {
-		return true, nil
-	}
-	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-		// Exit code 1 means file is not tracked (normal case)
-		return false, nil
-	}
-	// Other errors (like git not available, permissions, etc.) should be returned
-	return false, fmt.Errorf("failed to check if file is tracked: %w", err)
+	return getStatusFiles()
 }
 
-// GetFileDiff returns the unified diff for a specific file
-func GetFileDiff(filename string) (string, error) {
-	// Check if file is tracked
-	tracked, err := isFileTracked(filename)
-	if err != nil {
-		return "", fmt.Errorf("failed to check if file is tracked: %w", err)
+// GetFileDiff returns the unified diff for a specific file in the given mode
+func GetFileDiff(mode DiffMode, file ChangedFile) (string, error) {
+	if mode.Kind != ModeWorkingTree {
+		return getModeDiff(mode, file)
 	}
 
-	if tracked {
+	filename := file.Path
+	if !file.IsUntracked() {
 		// Use git diff for tracked files
-		cmd := exec.Command("git", "diff", filename)
+		cmd := exec.Command("git", "diff", "--", filename)
 		var out bytes.Buffer
 		cmd.Stdout = &out
 
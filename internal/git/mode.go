@@ -0,0 +1,190 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// emptyTreeHash is the hash of git's empty tree, used as the parent of root commits
+const emptyTreeHash = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// ModeKind identifies which set of changes is being reviewed
+type ModeKind int
+
+const (
+	ModeWorkingTree ModeKind = iota // Unstaged and untracked changes in the working tree
+	ModeStaged                      // Changes staged in the index
+	ModeCommit                      // Changes introduced by a single commit
+	ModeRange                       // Changes between two revisions (base..head)
+	ModeMergeBase                   // Changes on HEAD since it diverged from a branch (PR-style)
+)
+
+// modeKindNames are the names of each mode kind used when encoding a DiffMode
+var modeKindNames = map[ModeKind]string{
+	ModeWorkingTree: "working-tree",
+	ModeStaged:      "staged",
+	ModeCommit:      "commit",
+	ModeRange:       "range",
+	ModeMergeBase:   "merge-base",
+}
+
+// MarshalText encodes the kind by name, e.g. in saved sessions
+func (k ModeKind) MarshalText() ([]byte, error) {
+	name, ok := modeKindNames[k]
+	if !ok {
+		return nil, fmt.Errorf("unknown mode kind %d", k)
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText decodes a kind encoded by MarshalText
+func (k *ModeKind) UnmarshalText(text []byte) error {
+	for kind, name := range modeKindNames {
+		if name == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown mode kind %q", text)
+}
+
+// DiffMode describes which changes are being reviewed
+type DiffMode struct {
+	Kind   ModeKind `json:"kind"`
+	Commit string   `json:"commit,omitempty"` // Commit to review for ModeCommit
+	Base   string   `json:"base,omitempty"`   // Base revision for ModeRange
+	Head   string   `json:"head,omitempty"`   // Head revision for ModeRange
+	Branch string   `json:"branch,omitempty"` // Branch to compare against for ModeMergeBase
+}
+
+// WorkingTree returns the default mode, reviewing unstaged and untracked changes
+func WorkingTree() DiffMode {
+	return DiffMode{Kind: ModeWorkingTree}
+}
+
+// Staged returns a mode reviewing changes staged in the index
+func Staged() DiffMode {
+	return DiffMode{Kind: ModeStaged}
+}
+
+// Commit returns a mode reviewing the changes introduced by a single commit
+func Commit(sha string) DiffMode {
+	return DiffMode{Kind: ModeCommit, Commit: sha}
+}
+
+// Range returns a mode reviewing the changes between two revisions given as "base..head"
+func Range(spec string) (DiffMode, error) {
+	base, head, ok := strings.Cut(spec, "..")
+	if !ok || base == "" || head == "" || strings.HasPrefix(head, ".") {
+		return DiffMode{}, fmt.Errorf("invalid range %q, expected base..head", spec)
+	}
+	return DiffMode{Kind: ModeRange, Base: base, Head: head}, nil
+}
+
+// MergeBase returns a mode reviewing HEAD against its merge base with a branch, like a pull request
+func MergeBase(branch string) DiffMode {
+	return DiffMode{Kind: ModeMergeBase, Branch: branch}
+}
+
+// String describes the mode for display in the header
+func (m DiffMode) String() string {
+	switch m.Kind {
+	case ModeStaged:
+		return "staged"
+	case ModeCommit:
+		return fmt.Sprintf("commit %s", m.Commit)
+	case ModeRange:
+		return fmt.Sprintf("range %s..%s", m.Base, m.Head)
+	case ModeMergeBase:
+		return fmt.Sprintf("merge-base %s", m.Branch)
+	default:
+		return "working tree"
+	}
+}
+
+// Revision returns the revision whose changes are reviewed, the commit or the head of the
+// range, or "" when the changes sit on HEAD as they do in every other mode
+func (m DiffMode) Revision() string {
+	switch m.Kind {
+	case ModeCommit:
+		return m.Commit
+	case ModeRange:
+		return m.Head
+	default:
+		return ""
+	}
+}
+
+// diffArgs returns the git diff arguments that select the mode's changes
+func (m DiffMode) diffArgs() ([]string, error) {
+	switch m.Kind {
+	case ModeStaged:
+		return []string{"--cached"}, nil
+	case ModeCommit:
+		parent, err := commitParent(m.Commit)
+		if err != nil {
+			return nil, err
+		}
+		return []string{parent, m.Commit}, nil
+	case ModeRange:
+		return []string{m.Base + ".." + m.Head}, nil
+	case ModeMergeBase:
+		return []string{m.Branch + "...HEAD"}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// commitParent returns the first parent of a commit, or the empty tree for root commits
+func commitParent(sha string) (string, error) {
+	if err := exec.Command("git", "rev-parse", "--verify", "--quiet", sha+"^{commit}").Run(); err != nil {
+		return "", fmt.Errorf("unknown commit %s: %w", sha, err)
+	}
+	if err := exec.Command("git", "rev-parse", "--verify", "--quiet", sha+"^").Run(); err != nil {
+		return emptyTreeHash, nil
+	}
+	return sha + "^", nil
+}
+
+// getModeFiles returns the files changed by a mode other than the working tree
+func getModeFiles(mode DiffMode) ([]ChangedFile, error) {
+	args, err := mode.diffArgs()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("git", append([]string{"diff", "--name-status", "-z", "-M"}, args...)...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to get changed files for %s: %w", mode, err)
+	}
+
+	return parseNameStatus(out.String())
+}
+
+// getModeDiff returns the diff of a single file for a mode other than the working tree
+func getModeDiff(mode DiffMode, file ChangedFile) (string, error) {
+	args, err := mode.diffArgs()
+	if err != nil {
+		return "", err
+	}
+
+	// Both paths are needed for git to detect a rename or copy
+	args = append(append([]string{"diff", "-M"}, args...), "--", file.Path)
+	if file.OrigPath != "" {
+		args = append(args, file.OrigPath)
+	}
+	cmd := exec.Command("git", args...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to get diff for %s: %w", file.Path, err)
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,57 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// RepoInfo identifies the repository being reviewed and the commit it's on
+type RepoInfo struct {
+	GitDir string // Absolute path of the .git directory
+	Branch string // Current branch, "" when HEAD is detached
+	Head   string // Commit SHA of HEAD, "" before the first commit
+	User   string // Configured user.name, "" if not set
+}
+
+// GetRepoInfo returns the git directory, current branch, HEAD commit and user of the repository
+func GetRepoInfo() (RepoInfo, error) {
+	gitDir, err := revParse("--absolute-git-dir")
+	if err != nil {
+		return RepoInfo{}, fmt.Errorf("failed to find git directory: %w", err)
+	}
+
+	// Both fail harmlessly on a detached HEAD or an empty repository
+	branch, _ := output("git", "symbolic-ref", "--short", "-q", "HEAD")
+	head, _ := revParse("--verify", "-q", "HEAD")
+	user, _ := output("git", "config", "user.name")
+
+	return RepoInfo{GitDir: gitDir, Branch: branch, Head: head, User: user}, nil
+}
+
+// ResolveRevision returns the commit SHA a revision such as a branch name or "HEAD~2" points to
+func ResolveRevision(rev string) (string, error) {
+	sha, err := revParse("--verify", "-q", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %s: %w", rev, err)
+	}
+	return sha, nil
+}
+
+// revParse runs git rev-parse with the given arguments and returns its trimmed output
+func revParse(args ...string) (string, error) {
+	return output("git", append([]string{"rev-parse"}, args...)...)
+}
+
+// output runs a command and returns its trimmed standard output
+func output(name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.String()), nil
+}
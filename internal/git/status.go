@@ -0,0 +1,165 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Status codes used in ChangedFile, matching git status --porcelain
+const (
+	StatusUnmodified byte = '.'
+	StatusModified   byte = 'M'
+	StatusTypeChange byte = 'T'
+	StatusAdded      byte = 'A'
+	StatusDeleted    byte = 'D'
+	StatusRenamed    byte = 'R'
+	StatusCopied     byte = 'C'
+	StatusUnmerged   byte = 'U'
+	StatusUntracked  byte = '?'
+)
+
+// ChangedFile is a file with changes to review
+type ChangedFile struct {
+	Path           string // Current path of the file
+	OrigPath       string // Path before a rename or copy, "" otherwise
+	IndexStatus    byte   // Status of the file in the index (staged changes)
+	WorktreeStatus byte   // Status of the file in the working tree (unstaged changes)
+	Submodule      bool   // Whether the path is a submodule
+}
+
+// Status returns the most relevant status of the file, preferring unstaged changes
+func (f ChangedFile) Status() byte {
+	if f.WorktreeStatus != StatusUnmodified && f.WorktreeStatus != 0 {
+		return f.WorktreeStatus
+	}
+	return f.IndexStatus
+}
+
+// IsUntracked reports whether the file is not yet tracked by git
+func (f ChangedFile) IsUntracked() bool {
+	return f.WorktreeStatus == StatusUntracked
+}
+
+// DisplayName returns the path, showing the original path for renames and copies
+func (f ChangedFile) DisplayName() string {
+	if f.OrigPath != "" {
+		return fmt.Sprintf("%s → %s", f.OrigPath, f.Path)
+	}
+	return f.Path
+}
+
+// getStatusFiles returns changed and untracked files from git status --porcelain=v2 -z
+func getStatusFiles() ([]ChangedFile, error) {
+	cmd := exec.Command("git", "status", "--porcelain=v2", "-z", "--untracked-files=all")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to get status files: %w", err)
+	}
+
+	return parseStatusV2(out.String())
+}
+
+// parseStatusV2 parses NUL separated git status --porcelain=v2 output. Paths are
+// never quoted in -z mode, so spaces, unicode and "->" in names are preserved.
+func parseStatusV2(output string) ([]ChangedFile, error) {
+	files := []ChangedFile{}
+	entries := strings.Split(output, "\x00")
+
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if entry == "" {
+			continue
+		}
+
+		switch entry[0] {
+		case '1':
+			// 1 <XY> <sub> <mH> <mI> <mW> <hH> <hI> <path>
+			fields := strings.SplitN(entry, " ", 9)
+			if len(fields) != 9 {
+				return nil, fmt.Errorf("malformed status entry: %q", entry)
+			}
+			files = append(files, newStatusFile(fields[1], fields[2], fields[8], ""))
+		case '2':
+			// 2 <XY> <sub> <mH> <mI> <mW> <hH> <hI> <X><score> <path>, followed by <origPath>
+			fields := strings.SplitN(entry, " ", 10)
+			if len(fields) != 10 || i+1 >= len(entries) || entries[i+1] == "" {
+				return nil, fmt.Errorf("malformed rename entry: %q", entry)
+			}
+			i++
+			files = append(files, newStatusFile(fields[1], fields[2], fields[9], entries[i]))
+		case 'u':
+			// u <XY> <sub> <m1> <m2> <m3> <mW> <h1> <h2> <h3> <path>
+			fields := strings.SplitN(entry, " ", 11)
+			if len(fields) != 11 {
+				return nil, fmt.Errorf("malformed unmerged entry: %q", entry)
+			}
+			file := newStatusFile(fields[1], fields[2], fields[10], "")
+			file.IndexStatus, file.WorktreeStatus = StatusUnmerged, StatusUnmerged
+			files = append(files, file)
+		case '?':
+			// ? <path>
+			files = append(files, ChangedFile{
+				Path:           strings.TrimPrefix(entry, "? "),
+				IndexStatus:    StatusUntracked,
+				WorktreeStatus: StatusUntracked,
+			})
+		case '!', '#':
+			// Ignored files and branch headers aren't reviewed
+		default:
+			return nil, fmt.Errorf("unknown status entry: %q", entry)
+		}
+	}
+
+	return files, nil
+}
+
+// newStatusFile builds a ChangedFile from the XY and submodule fields of a status entry
+func newStatusFile(xy, sub, path, origPath string) ChangedFile {
+	file := ChangedFile{
+		Path:      path,
+		OrigPath:  origPath,
+		Submodule: strings.HasPrefix(sub, "S"),
+	}
+	if len(xy) == 2 {
+		file.IndexStatus, file.WorktreeStatus = xy[0], xy[1]
+	}
+	return file
+}
+
+// parseNameStatus parses NUL separated git diff --name-status -z output. Changes
+// between revisions have no working tree component, so they're reported as index status.
+func parseNameStatus(output string) ([]ChangedFile, error) {
+	files := []ChangedFile{}
+	entries := strings.Split(output, "\x00")
+
+	for i := 0; i < len(entries); i++ {
+		status := entries[i]
+		if status == "" {
+			continue
+		}
+		if i+1 >= len(entries) || entries[i+1] == "" {
+			return nil, fmt.Errorf("missing path for status %q", status)
+		}
+
+		file := ChangedFile{IndexStatus: status[0], WorktreeStatus: StatusUnmodified}
+		i++
+		file.Path = entries[i]
+
+		// Renames and copies (R100, C75) are followed by the old and new paths
+		if status[0] == StatusRenamed || status[0] == StatusCopied {
+			if i+1 >= len(entries) || entries[i+1] == "" {
+				return nil, fmt.Errorf("missing new path for status %q", status)
+			}
+			i++
+			file.OrigPath, file.Path = file.Path, entries[i]
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,77 @@
+package git
+
+import (
+	"testing"
+)
+
+func TestParseStatusV2(t *testing.T) {
+	output := "1 .M N... 100644 100644 100644 1111111 1111111 main.go\x00" +
+		"2 R. N... 100644 100644 100644 2222222 2222222 R100 new name.go\x00old -> name.go\x00" +
+		"1 A. N... 000000 100644 100644 0000000 3333333 café.txt\x00" +
+		"1 .D N... 100644 100644 000000 4444444 4444444 gone.go\x00" +
+		"1 .M SC.. 160000 160000 160000 5555555 5555555 vendor/lib\x00" +
+		"u UU N... 100644 100644 100644 100644 6666666 7777777 8888888 conflict.go\x00" +
+		"? untracked file.txt\x00" +
+		"! ignored.log\x00"
+
+	files, err := parseStatusV2(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []ChangedFile{
+		{Path: "main.go", IndexStatus: StatusUnmodified, WorktreeStatus: StatusModified},
+		{Path: "new name.go", OrigPath: "old -> name.go", IndexStatus: StatusRenamed, WorktreeStatus: StatusUnmodified},
+		{Path: "café.txt", IndexStatus: StatusAdded, WorktreeStatus: StatusUnmodified},
+		{Path: "gone.go", IndexStatus: StatusUnmodified, WorktreeStatus: StatusDeleted},
+		{Path: "vendor/lib", IndexStatus: StatusUnmodified, WorktreeStatus: StatusModified, Submodule: true},
+		{Path: "conflict.go", IndexStatus: StatusUnmerged, WorktreeStatus: StatusUnmerged},
+		{Path: "untracked file.txt", IndexStatus: StatusUntracked, WorktreeStatus: StatusUntracked},
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("expected %d files, got %d: %+v", len(expected), len(files), files)
+	}
+	for i, want := range expected {
+		if files[i] != want {
+			t.Errorf("file %d: expected %+v, got %+v", i, want, files[i])
+		}
+	}
+
+	if files[1].Status() != StatusRenamed || files[3].Status() != StatusDeleted {
+		t.Errorf("expected Status to prefer the worktree status when set")
+	}
+	if got := files[1].DisplayName(); got != "old -> name.go → new name.go" {
+		t.Errorf("unexpected display name %q", got)
+	}
+
+	if _, err := parseStatusV2("1 .M main.go\x00"); err == nil {
+		t.Errorf("expected error for truncated entry")
+	}
+}
+
+func TestParseNameStatus(t *testing.T) {
+	output := "M\x00main.go\x00R087\x00old.go\x00new dir/new.go\x00D\x00gone.go\x00"
+
+	files, err := parseNameStatus(output)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []ChangedFile{
+		{Path: "main.go", IndexStatus: StatusModified, WorktreeStatus: StatusUnmodified},
+		{Path: "new dir/new.go", OrigPath: "old.go", IndexStatus: StatusRenamed, WorktreeStatus: StatusUnmodified},
+		{Path: "gone.go", IndexStatus: StatusDeleted, WorktreeStatus: StatusUnmodified},
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("expected %d files, got %d: %+v", len(expected), len(files), files)
+	}
+	for i, want := range expected {
+		if files[i] != want {
+			t.Errorf("file %d: expected %+v, got %+v", i, want, files[i])
+		}
+	}
+
+	if _, err := parseNameStatus("R100\x00old.go\x00"); err == nil {
+		t.Errorf("expected error for rename without a new path")
+	}
+}
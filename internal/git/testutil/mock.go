@@ -1,13 +1,23 @@
 package testutil
 
+import (
+	"fmt"
+
+	"github.com/samverrall/review-ui/internal/git"
+)
+
 // MockGitClient allows us to mock git operations for testing
 type MockGitClient struct {
 	isRepo       bool
-	changedFiles []string
+	changedFiles []git.ChangedFile
 	fileDiffs    map[string]string
 	repoError    error
 	filesError   error
 	diffError    error
+	lastMode     git.DiffMode
+	repoInfo     git.RepoInfo
+	fingerprint  string
+	revisions    map[string]string
 }
 
 // NewMockGitClient creates a new mock git client with default values
@@ -23,8 +33,21 @@ func (m *MockGitClient) WithIsRepo(isRepo bool) *MockGitClient {
 	return m
 }
 
-// WithChangedFiles sets the mock to return the specified changed files
+// WithChangedFiles sets the mock to return the specified paths as modified files
 func (m *MockGitClient) WithChangedFiles(files []string) *MockGitClient {
+	m.changedFiles = make([]git.ChangedFile, 0, len(files))
+	for _, file := range files {
+		m.changedFiles = append(m.changedFiles, git.ChangedFile{
+			Path:           file,
+			IndexStatus:    git.StatusUnmodified,
+			WorktreeStatus: git.StatusModified,
+		})
+	}
+	return m
+}
+
+// WithChangedFileEntries sets the mock to return the specified changed files with their status
+func (m *MockGitClient) WithChangedFileEntries(files []git.ChangedFile) *MockGitClient {
 	m.changedFiles = files
 	return m
 }
@@ -38,6 +61,27 @@ func (m *MockGitClient) WithFileDiff(filename, diff string) *MockGitClient {
 	return m
 }
 
+// WithRepoInfo sets the repository details returned by GetRepoInfo
+func (m *MockGitClient) WithRepoInfo(info git.RepoInfo) *MockGitClient {
+	m.repoInfo = info
+	return m
+}
+
+// WithFingerprint sets the value returned by GetFingerprint, changing it simulates edits on disk
+func (m *MockGitClient) WithFingerprint(fingerprint string) *MockGitClient {
+	m.fingerprint = fingerprint
+	return m
+}
+
+// WithRevision sets the commit SHA a revision resolves to, other revisions are unknown
+func (m *MockGitClient) WithRevision(rev, sha string) *MockGitClient {
+	if m.revisions == nil {
+		m.revisions = make(map[string]string)
+	}
+	m.revisions[rev] = sha
+	return m
+}
+
 // WithRepoError sets the mock to return the specified error for repo checks
 func (m *MockGitClient) WithRepoError(err error) *MockGitClient {
 	m.repoError = err
@@ -57,21 +101,45 @@ func (m *MockGitClient) WithDiffError(err error) *MockGitClient {
 }
 
 // GetChangedFilesForTest returns the configured changed files for testing
-func (m *MockGitClient) GetChangedFilesForTest() []string {
+func (m *MockGitClient) GetChangedFilesForTest() []git.ChangedFile {
 	return m.changedFiles
 }
 
+// LastMode returns the diff mode passed to the most recent git operation
+func (m *MockGitClient) LastMode() git.DiffMode {
+	return m.lastMode
+}
+
 func (m *MockGitClient) IsGitRepo() (bool, error) {
 	return m.isRepo, m.repoError
 }
 
-func (m *MockGitClient) GetChangedFiles() ([]string, error) {
+func (m *MockGitClient) GetChangedFiles(mode git.DiffMode) ([]git.ChangedFile, error) {
+	m.lastMode = mode
 	return m.changedFiles, m.filesError
 }
 
-func (m *MockGitClient) GetFileDiff(filename string) (string, error) {
-	if diff, exists := m.fileDiffs[filename]; exists {
+func (m *MockGitClient) GetFileDiff(mode git.DiffMode, file git.ChangedFile) (string, error) {
+	m.lastMode = mode
+	if diff, exists := m.fileDiffs[file.Path]; exists {
 		return diff, m.diffError
 	}
 	return "", m.diffError
 }
+
+func (m *MockGitClient) GetRepoInfo() (git.RepoInfo, error) {
+	return m.repoInfo, m.repoError
+}
+
+func (m *MockGitClient) GetFingerprint(mode git.DiffMode) (string, error) {
+	m.lastMode = mode
+	return m.fingerprint, m.filesError
+}
+
+func (m *MockGitClient) ResolveRevision(rev string) (string, error) {
+	sha, ok := m.revisions[rev]
+	if !ok {
+		return "", fmt.Errorf("unknown revision %s", rev)
+	}
+	return sha, nil
+}
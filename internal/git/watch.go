@@ -0,0 +1,49 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// GetFingerprint returns a value that changes whenever the changes reviewed in a mode may
+// have changed, so they can be watched by polling. For the working tree and index this
+// covers git status, including the index object of each file, and the size and modification
+// time of every changed file; for other modes it covers the revisions being compared.
+func GetFingerprint(mode DiffMode) (string, error) {
+	hash := sha256.New()
+
+	switch mode.Kind {
+	case ModeWorkingTree, ModeStaged:
+		status, err := output("git", "status", "--porcelain=v2", "-z", "--untracked-files=all", "--branch")
+		if err != nil {
+			return "", fmt.Errorf("failed to get status: %w", err)
+		}
+		hash.Write([]byte(status))
+
+		// Editing an already modified file doesn't change its status line
+		files, err := parseStatusV2(status)
+		if err != nil {
+			return "", err
+		}
+		for _, file := range files {
+			if info, err := os.Stat(file.Path); err == nil {
+				fmt.Fprintf(hash, "%s\x00%d\x00%d\x00", file.Path, info.Size(), info.ModTime().UnixNano())
+			}
+		}
+
+	default:
+		args, err := mode.diffArgs()
+		if err != nil {
+			return "", err
+		}
+		revisions, err := revParse(append([]string{"HEAD"}, args...)...)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve revisions for %s: %w", mode, err)
+		}
+		hash.Write([]byte(revisions))
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,166 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/samverrall/review-ui/internal/diff"
+	"github.com/samverrall/review-ui/internal/git"
+)
+
+// Version of the session file format, bumped on incompatible changes
+const Version = 1
+
+// Session is the saved state of a review
+type Session struct {
+	Version    int          `json:"version"`
+	Branch     string       `json:"branch"`      // Branch the review was on, "" when HEAD was detached
+	Head       string       `json:"head"`        // Commit SHA of HEAD when the review was saved
+	Mode       git.DiffMode `json:"mode"`        // Which changes were being reviewed
+	Comments   []Comment    `json:"comments"`    // Review comments
+	Reviewed   []string     `json:"reviewed"`    // Files marked as reviewed
+	File       string       `json:"file"`        // File the cursor was in
+	CursorLine int          `json:"cursor_line"` // Line the cursor was on, 0 if it wasn't on a line
+	CursorSide diff.Side    `json:"cursor_side"` // Side CursorLine refers to
+	SavedAt    time.Time    `json:"saved_at"`
+}
+
+// Comment is a saved review comment, anchored to real line numbers of a file
+type Comment struct {
+	ID        int          `json:"id"`
+	File      string       `json:"file"`
+	Side      diff.Side    `json:"side"`
+	StartLine int          `json:"start_line"`
+	EndLine   int          `json:"end_line"`
+	Body      string       `json:"body"`
+	Severity  string       `json:"severity,omitempty"` // Category such as "blocking" or "nit", "" if untagged
+	ReplyTo   int          `json:"reply_to,omitempty"` // ID of the comment starting the thread this replies to
+	Author    string       `json:"author,omitempty"`
+	CreatedAt time.Time    `json:"created_at,omitzero"`
+	Resolved  bool         `json:"resolved,omitempty"` // Whether the thread this comment starts is resolved
+	Anchor    *diff.Anchor `json:"anchor,omitempty"`   // Code the comment was written against
+	Outdated  bool         `json:"outdated,omitempty"` // Whether the code was missing from the diff when saved
+}
+
+// IsEmpty reports whether the session has nothing worth resuming
+func (s *Session) IsEmpty() bool {
+	return len(s.Comments) == 0 && len(s.Reviewed) == 0
+}
+
+// Store saves sessions as JSON files in a directory, normally .git/review-ui
+type Store struct {
+	dir string
+}
+
+// NewStore creates a store that keeps sessions under the given git directory
+func NewStore(gitDir string) *Store {
+	return &Store{dir: filepath.Join(gitDir, "review-ui")}
+}
+
+// Load returns the session saved for a branch, HEAD and mode. If HEAD has moved since, e.g.
+// the changes were committed, the most recently saved session of the branch in the same mode
+// is returned instead. It returns nil if there is no session to resume.
+func (s *Store) Load(branch, head string, mode git.DiffMode) (*Session, error) {
+	sess, err := s.read(filepath.Join(s.dir, fileName(branch, head, mode)))
+	if err != nil || sess != nil {
+		return sess, err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(s.dir, branchPrefix(branch)+"*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	var latest *Session
+	for _, path := range matches {
+		candidate, err := s.read(path)
+		if err != nil {
+			return nil, err
+		}
+		if candidate != nil && candidate.Branch == branch && candidate.Mode == mode && (latest == nil || candidate.SavedAt.After(latest.SavedAt)) {
+			latest = candidate
+		}
+	}
+	return latest, nil
+}
+
+// Save writes a session, replacing any session saved for the same branch, HEAD and mode
+func (s *Store) Save(sess *Session) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	sess.Version = Version
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated session
+	path := filepath.Join(s.dir, fileName(sess.Branch, sess.Head, sess.Mode))
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
+
+// read loads a session file, returning nil if it doesn't exist
+func (s *Store) read(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("failed to decode session %s: %w", filepath.Base(path), err)
+	}
+	if sess.Version != Version {
+		return nil, fmt.Errorf("unsupported session version %d in %s", sess.Version, filepath.Base(path))
+	}
+	return &sess, nil
+}
+
+// fileName returns the file a session is saved in, e.g. "feature-login@1a2b3c4d5e6f.json".
+// Reviews of anything but the working tree are kept apart by their mode, e.g.
+// "feature-login@staged@1a2b3c4d5e6f.json", so reviewing one never replaces another.
+func fileName(branch, head string, mode git.DiffMode) string {
+	if head == "" {
+		head = "unborn"
+	}
+	if mode.Kind != git.ModeWorkingTree {
+		head = safeName(mode.String()) + "@" + head
+	}
+	return branchPrefix(branch) + head + ".json"
+}
+
+// branchPrefix returns the file name prefix shared by every session of a branch
+func branchPrefix(branch string) string {
+	if branch == "" {
+		branch = "detached"
+	}
+	return safeName(branch) + "@"
+}
+
+// safeName replaces the characters of a branch name or mode that aren't safe in file names,
+// such as slashes and spaces
+func safeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '-'
+	}, name)
+}
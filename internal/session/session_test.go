@@ -0,0 +1,103 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samverrall/review-ui/internal/diff"
+	"github.com/samverrall/review-ui/internal/git"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	gitDir := t.TempDir()
+	store := NewStore(gitDir)
+
+	// Nothing saved yet
+	sess, err := store.Load("feature/login", "abc123", git.MergeBase("main"))
+	if err != nil || sess != nil {
+		t.Fatalf("expected no session, got %+v (err=%v)", sess, err)
+	}
+
+	saved := &Session{
+		Branch:     "feature/login",
+		Head:       "abc123",
+		Mode:       git.MergeBase("main"),
+		Comments:   []Comment{{File: "main.go", Side: diff.SideOld, StartLine: 3, EndLine: 5, Body: "why?"}},
+		Reviewed:   []string{"go.mod"},
+		File:       "main.go",
+		CursorLine: 4,
+		CursorSide: diff.SideOld,
+		SavedAt:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(gitDir, "review-ui", "feature-login@merge-base-main@abc123.json")); err != nil {
+		t.Errorf("expected session file keyed by branch, mode and HEAD: %v", err)
+	}
+
+	loaded, err := store.Load("feature/login", "abc123", git.MergeBase("main"))
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, saved) {
+		t.Errorf("expected %+v, got %+v", saved, loaded)
+	}
+
+	// Sessions of other branches aren't offered
+	if other, err := store.Load("main", "abc123", git.MergeBase("main")); err != nil || other != nil {
+		t.Errorf("expected no session for another branch, got %+v (err=%v)", other, err)
+	}
+}
+
+func TestStoreFallsBackToLatestSessionOfBranch(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	for i, head := range []string{"aaa", "bbb"} {
+		sess := &Session{Branch: "dev", Head: head, SavedAt: time.Unix(int64(i), 0)}
+		if err := store.Save(sess); err != nil {
+			t.Fatalf("failed to save: %v", err)
+		}
+	}
+
+	// HEAD moved since the last save, e.g. the reviewed changes were committed
+	sess, err := store.Load("dev", "ccc", git.WorkingTree())
+	if err != nil || sess == nil || sess.Head != "bbb" {
+		t.Errorf("expected the latest session of the branch, got %+v (err=%v)", sess, err)
+	}
+}
+
+func TestStoreKeepsModesApart(t *testing.T) {
+	gitDir := t.TempDir()
+	store := NewStore(gitDir)
+
+	for _, mode := range []git.DiffMode{git.WorkingTree(), git.Staged()} {
+		sess := &Session{Branch: "dev", Head: "aaa", Mode: mode, Reviewed: []string{mode.String()}}
+		if err := store.Save(sess); err != nil {
+			t.Fatalf("failed to save: %v", err)
+		}
+	}
+
+	// Working tree reviews keep the name they had before modes were part of it
+	if _, err := os.Stat(filepath.Join(gitDir, "review-ui", "dev@aaa.json")); err != nil {
+		t.Errorf("expected working tree session keyed by branch and HEAD: %v", err)
+	}
+
+	for _, mode := range []git.DiffMode{git.WorkingTree(), git.Staged()} {
+		sess, err := store.Load("dev", "aaa", mode)
+		if err != nil || sess == nil || sess.Mode != mode || sess.Reviewed[0] != mode.String() {
+			t.Errorf("expected the %s session, got %+v (err=%v)", mode, sess, err)
+		}
+	}
+
+	// Falling back to the latest session of the branch doesn't cross modes either
+	if sess, err := store.Load("dev", "bbb", git.Commit("abc")); err != nil || sess != nil {
+		t.Errorf("expected no session for another mode, got %+v (err=%v)", sess, err)
+	}
+	if sess, err := store.Load("dev", "bbb", git.Staged()); err != nil || sess == nil || sess.Mode != git.Staged() {
+		t.Errorf("expected the staged session, got %+v (err=%v)", sess, err)
+	}
+}
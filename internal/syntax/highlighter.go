@@ -84,18 +84,10 @@ func NewHighlighter() *Highlighter {
 
 // Highlight applies syntax highlighting to the given code for the specified file
 func (h *Highlighter) Highlight(filename, code string) (string, error) {
-	// Detect language from filename
-	lexer := lexers.Match(filename)
+	lexer := lexerFor(filename)
 	if lexer == nil {
-		// Try to detect from extension if filename matching fails
-		ext := filepath.Ext(filename)
-		if ext != "" {
-			lexer = lexers.Get(ext[1:]) // Remove the leading dot
-		}
-		if lexer == nil {
-			// Fallback to plain text
-			return code, nil
-		}
+		// Fallback to plain text
+		return code, nil
 	}
 
 	// Ensure we have a lexer
@@ -117,3 +109,29 @@ func (h *Highlighter) Highlight(filename, code string) (string, error) {
 	return buf.String(), nil
 }
 
+// Language returns the name of the language of a file for markdown code fences, e.g. "go",
+// picked the same way as the lexer used for highlighting. It returns "" if it's unknown.
+func Language(filename string) string {
+	lexer := lexerFor(filename)
+	if lexer == nil {
+		return ""
+	}
+	config := lexer.Config()
+	if len(config.Aliases) > 0 {
+		return config.Aliases[0]
+	}
+	return strings.ToLower(config.Name)
+}
+
+// lexerFor detects the language of a file from its name, returning nil if it's unknown
+func lexerFor(filename string) chroma.Lexer {
+	lexer := lexers.Match(filename)
+	if lexer == nil {
+		// Try to detect from extension if filename matching fails
+		ext := filepath.Ext(filename)
+		if ext != "" {
+			lexer = lexers.Get(ext[1:]) // Remove the leading dot
+		}
+	}
+	return lexer
+}
@@ -18,4 +18,7 @@ const (
 	SubtleText   = lipgloss.Color("244") // Muted text
 	AccentBg     = lipgloss.Color("236") // Accent background
 	CursorLineBg = lipgloss.Color("238") // Cursor line background (visibly lighter for cursor visibility)
+
+	AddedEmphasisBg   = lipgloss.Color("22") // Dark green background for changed words in added lines
+	DeletedEmphasisBg = lipgloss.Color("52") // Dark red background for changed words in deleted lines
 )
@@ -0,0 +1,192 @@
+package ui
+
+import (
+	"github.com/samverrall/review-ui/internal/diff"
+)
+
+// addComment adds a comment at a location, capturing the code it was written against
+// so it can be found again if the diff changes
+func (m *model) addComment(loc commentLocation, body string, sev severity) {
+	c := m.newComment(body, sev)
+	m.execute(&addCommentCommand{saved: savedComment{loc: loc, comment: c, index: len(m.comments[loc])}})
+}
+
+// summary returns the review summary, if one has been written
+func (m *model) summary() (comment, bool) {
+	if comments := m.comments[summaryLocation]; len(comments) > 0 {
+		return comments[0], true
+	}
+	return comment{}, false
+}
+
+// cursorCommentTarget returns the location a new comment would attach to: the file lines
+// covered by the selection, or the line under the cursor
+func (m *model) cursorCommentTarget() (commentLocation, bool) {
+	if m.selectionMode {
+		return m.getCommentKeyForRange(m.getSelectionRange())
+	}
+	return m.getCommentKey(m.cursorLine)
+}
+
+// reanchorComments moves the comments on a file to wherever their code now appears in its
+// diff. Comments whose code can't be found are flagged as outdated rather than moved.
+func (m *model) reanchorComments(file string, fd *fileDiff) {
+	type move struct {
+		from, to commentLocation
+	}
+
+	var moves []move
+	for _, loc := range m.sortedCommentLocations() {
+		anchor, exists := m.anchors[loc]
+		if loc.File != file || !exists || anchor.IsEmpty() {
+			continue
+		}
+
+		start, end, ok := anchor.Locate(fd.rows, loc.Side, loc.StartLine)
+		if !ok {
+			m.outdated[loc] = true
+			continue
+		}
+		delete(m.outdated, loc)
+		if start != loc.StartLine || end != loc.EndLine {
+			to := loc
+			to.StartLine, to.EndLine = start, end
+			moves = append(moves, move{from: loc, to: to})
+		}
+	}
+
+	// Detach every moved comment before reattaching any, so comments shifting onto each
+	// other's old lines aren't merged
+	type detached struct {
+		comments []comment
+		anchor   diff.Anchor
+	}
+	pending := make([]detached, len(moves))
+	for i, mv := range moves {
+		pending[i] = detached{comments: m.comments[mv.from], anchor: m.anchors[mv.from]}
+		delete(m.comments, mv.from)
+		delete(m.anchors, mv.from)
+	}
+	for i, mv := range moves {
+		m.comments[mv.to] = append(m.comments[mv.to], pending[i].comments...)
+		if _, exists := m.anchors[mv.to]; !exists {
+			m.anchors[mv.to] = pending[i].anchor
+		}
+		delete(m.outdated, mv.to)
+	}
+}
+
+// outdatedCount returns the number of outdated comments on a file
+func (m *model) outdatedCount(file string) int {
+	count := 0
+	for loc := range m.outdated {
+		if loc.File == file {
+			count += len(m.comments[loc])
+		}
+	}
+	return count
+}
+
+// cursorComments returns the comments covering the line under the cursor, in display order.
+// Notes on the whole file and outdated comments are listed under the file header, so
+// they're under the cursor on row 0.
+func (m *model) cursorComments() []comment {
+	file := m.currentFile()
+	var comments []comment
+	for _, loc := range m.sortedCommentLocations() {
+		if loc.File != file {
+			continue
+		}
+		onCursor := false
+		if loc.isFileLevel() || m.outdated[loc] {
+			// Shown under the file header rather than on a line
+			onCursor = m.cursorLine == 0
+		} else if line := m.lineNumber(m.cursorLine, loc.Side); line != 0 {
+			onCursor = line >= loc.StartLine && line <= loc.EndLine
+		}
+		if !onCursor {
+			continue
+		}
+		for _, t := range m.visibleThreads(loc) {
+			comments = append(comments, t.comments()...)
+		}
+	}
+	return comments
+}
+
+// focusedComment returns the comment under the cursor that edits and deletes apply to,
+// chosen with [ and ] when the cursor is on more than one
+func (m *model) focusedComment() (comment, commentLocation, bool) {
+	comments := m.cursorComments()
+	if len(comments) == 0 {
+		return comment{}, commentLocation{}, false
+	}
+	c := comments[min(m.commentFocus, len(comments)-1)]
+	c, loc, ok := m.findComment(c.ID)
+	return c, loc, ok
+}
+
+// cycleCommentFocus moves the focus to the next or previous comment under the cursor
+func (m *model) cycleCommentFocus(delta int) {
+	count := len(m.cursorComments())
+	if count == 0 {
+		return
+	}
+	m.commentFocus = ((min(m.commentFocus, count-1)+delta)%count + count) % count
+}
+
+// findComment returns a comment and its location by ID
+func (m *model) findComment(id int) (comment, commentLocation, bool) {
+	for loc, comments := range m.comments {
+		for _, c := range comments {
+			if c.ID == id {
+				return c, loc, true
+			}
+		}
+	}
+	return comment{}, commentLocation{}, false
+}
+
+// editComment replaces the body and severity of a comment, keeping its ID and location
+func (m *model) editComment(id int, body string, sev severity) bool {
+	c, _, ok := m.findComment(id)
+	if !ok {
+		return false
+	}
+	edited := c
+	edited.Body, edited.Severity = body, sev
+	m.execute(&editCommentCommand{before: c, after: edited})
+	return true
+}
+
+// replaceComment replaces the comment with the same ID without recording it in the history
+func (m *model) replaceComment(c comment) {
+	_, loc, ok := m.findComment(c.ID)
+	if !ok {
+		return
+	}
+	for i := range m.comments[loc] {
+		if m.comments[loc][i].ID == c.ID {
+			m.comments[loc][i] = c
+		}
+	}
+}
+
+// deleteComment removes a comment, along with its replies if it starts a thread, forgetting
+// its location once no comments are left on it
+func (m *model) deleteComment(id int) bool {
+	c, loc, ok := m.findComment(id)
+	if !ok {
+		return false
+	}
+	ids := []int{id}
+	if c.ReplyTo == 0 {
+		for _, reply := range m.comments[loc] {
+			if reply.ReplyTo == id {
+				ids = append(ids, reply.ID)
+			}
+		}
+	}
+	m.execute(&deleteCommentCommand{ids: ids})
+	return true
+}
@@ -0,0 +1,105 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editorScissors separates the comment from the code context in the file opened in $EDITOR.
+// Everything from this line down is ignored, as with git's commit message scissors.
+const editorScissors = "# ------------------------ >8 ------------------------"
+
+// editorFinishedMsg is sent when the editor opened by openEditor exits
+type editorFinishedMsg struct {
+	target commentLocation // Location the comment is for
+	path   string          // Temp file holding the comment
+	err    error
+}
+
+// openEditor suspends the TUI and opens $EDITOR on a temp file to write a comment for a
+// location, with the commented code below the scissors line for reference
+func (m *model) openEditor(target commentLocation) tea.Cmd {
+	file, err := os.CreateTemp("", "review-comment-*.md")
+	if err != nil {
+		m.statusMessage = fmt.Sprintf("✗ Failed to create comment file: %v", err)
+		return nil
+	}
+	_, err = file.WriteString(m.editorTemplate(target))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		m.statusMessage = fmt.Sprintf("✗ Failed to write comment file: %v", err)
+		return nil
+	}
+
+	// $EDITOR may include arguments, e.g. "code --wait"
+	args := strings.Fields(os.Getenv("EDITOR"))
+	if len(args) == 0 {
+		args = []string{"vi"}
+	}
+	cmd := exec.Command(args[0], append(args[1:], file.Name())...)
+
+	path := file.Name()
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return editorFinishedMsg{target: target, path: path, err: err}
+	})
+}
+
+// handleEditorFinished adds the comment written in $EDITOR, if any
+func (m *model) handleEditorFinished(msg editorFinishedMsg) {
+	defer os.Remove(msg.path)
+	if msg.err != nil {
+		m.statusMessage = fmt.Sprintf("✗ Editor failed: %v", msg.err)
+		return
+	}
+
+	content, err := os.ReadFile(msg.path)
+	if err != nil {
+		m.statusMessage = fmt.Sprintf("✗ Failed to read comment file: %v", err)
+		return
+	}
+	body := parseEditorComment(string(content))
+	if body == "" {
+		m.statusMessage = "Comment discarded, nothing was written"
+		return
+	}
+	sev, body := parseSeverityPrefix(body)
+	m.addComment(msg.target, body, sev)
+	m.statusMessage = fmt.Sprintf("💬 Added comment to %s", msg.target.label())
+}
+
+// editorTemplate returns the initial content of the file opened in $EDITOR: an empty
+// comment followed by the commented lines of the diff
+func (m *model) editorTemplate(target commentLocation) string {
+	var b strings.Builder
+	b.WriteString("\n\n")
+	b.WriteString(editorScissors + "\n")
+	b.WriteString("# Write your comment above this line, everything below it is ignored.\n")
+	b.WriteString("# Start it with blocking:, suggestion:, nit:, question: or praise: to tag it.\n")
+	b.WriteString(fmt.Sprintf("# Commenting on %s, %s:\n#\n", target.File, target.label()))
+
+	if fd, exists := m.diffs[target.File]; exists {
+		for _, row := range fd.rows {
+			if n := row.Number(target.Side); row.Line != nil && n >= target.StartLine && n <= target.EndLine {
+				b.WriteString(fmt.Sprintf("# %5d %s\n", n, row.Text))
+			}
+		}
+	}
+	return b.String()
+}
+
+// parseEditorComment returns the comment written above the scissors line, without the
+// surrounding blank lines
+func parseEditorComment(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if i := strings.Index(content, editorScissors); i >= 0 {
+		content = content[:i]
+	}
+	return strings.Trim(strings.TrimRight(content, " \t\n"), "\n")
+}
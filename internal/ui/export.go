@@ -0,0 +1,203 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+
+	"github.com/samverrall/review-ui/internal/diff"
+	"github.com/samverrall/review-ui/internal/export"
+	"github.com/samverrall/review-ui/internal/syntax"
+)
+
+// exportOptions controls which comments are exported and how they're arranged
+type exportOptions struct {
+	format          export.Format     // Format to export in, markdown if ""
+	groupBySeverity bool              // Group comments by severity, blocking first, rather than only by file
+	severities      map[severity]bool // Severities to export, nil to export every comment
+	code            bool              // Include the commented code with each comment
+	contextLines    int               // Lines of surrounding code to include with the commented code
+	diffMarkers     bool              // Keep the +/- markers on the code rather than stripping them
+}
+
+// exporter returns the exporter for the chosen format
+func (o exportOptions) exporter() (export.Exporter, error) {
+	var opts export.Options
+	if o.groupBySeverity {
+		for _, sev := range append(severities, severityNone) {
+			opts.SeverityGroups = append(opts.SeverityGroups, sev.String())
+		}
+	}
+	return export.New(o.format, opts)
+}
+
+// exportComments formats all comments for export in the chosen format
+func (m *model) exportComments() (string, error) {
+	review, err := m.review()
+	if err != nil {
+		return "", err
+	}
+	if review.IsEmpty() {
+		return "No comments to export.", nil
+	}
+
+	exporter, err := m.export.exporter()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := exporter.Export(&b, review); err != nil {
+		return "", fmt.Errorf("failed to export comments: %w", err)
+	}
+	return b.String(), nil
+}
+
+// review collects the open threads whose root comment has an exported severity, along with
+// the review summary. Resolved threads are left out.
+func (m *model) review() (export.Review, error) {
+	commit, err := m.reviewedCommit()
+	if err != nil {
+		return export.Review{}, fmt.Errorf("failed to resolve reviewed commit: %w", err)
+	}
+	review := export.Review{Mode: m.mode, Commit: commit, Generated: time.Now()}
+	if summary, ok := m.summary(); ok {
+		review.Summary = summary.Body
+	}
+
+	// Loading a diff re-anchors its comments, so every commented file is loaded before the
+	// locations are listed, e.g. files not opened since a review was resumed
+	for _, loc := range m.sortedCommentLocations() {
+		if !loc.isSummary() {
+			m.exportDiff(loc.File)
+		}
+	}
+
+	for _, loc := range m.sortedCommentLocations() {
+		if loc.isSummary() {
+			continue
+		}
+		for _, t := range commentThreads(m.comments[loc]) {
+			if t.resolved() || (m.export.severities != nil && !m.export.severities[t.root.Severity]) {
+				continue
+			}
+
+			c := export.Comment{
+				ID:        t.root.ID,
+				File:      loc.File,
+				Side:      loc.Side,
+				StartLine: loc.StartLine,
+				EndLine:   loc.EndLine,
+				Severity:  t.root.Severity.String(),
+				Body:      t.root.Body,
+				Author:    t.root.Author,
+				CreatedAt: t.root.CreatedAt,
+				Outdated:  m.outdated[loc],
+			}
+			if m.export.code && !loc.isFileLevel() {
+				c.Code = m.codeSnippet(loc, m.export.contextLines, m.export.diffMarkers)
+				c.Lines = m.codeSnippet(loc, 0, false)
+				c.Language = syntax.Language(loc.File)
+			}
+			for _, reply := range t.replies {
+				c.Replies = append(c.Replies, export.Reply{ID: reply.ID, Body: reply.Body, Author: reply.Author, CreatedAt: reply.CreatedAt})
+			}
+			review.Comments = append(review.Comments, c)
+		}
+	}
+	return review, nil
+}
+
+// reviewedCommit returns the commit SHA of the reviewed revision: the commit or the head of
+// the range, otherwise HEAD, which working tree, staged and merge-base changes sit on
+func (m *model) reviewedCommit() (string, error) {
+	rev := m.mode.Revision()
+	if rev == "" {
+		return m.repo.Head, nil
+	}
+	return m.gitClient.ResolveRevision(rev)
+}
+
+// codeSnippet returns the code a comment is on with up to context lines around it, keeping
+// the +/- markers if asked, or nil if the code isn't available
+func (m *model) codeSnippet(loc commentLocation, context int, markers bool) []string {
+	if m.outdated[loc] {
+		// The lines are no longer in the diff, so use the code the comment was written against
+		anchor, exists := m.anchors[loc]
+		if !exists {
+			return nil
+		}
+		var lines []string
+		lines = append(lines, anchor.Before[max(len(anchor.Before)-context, 0):]...)
+		lines = append(lines, anchor.Lines...)
+		return append(lines, anchor.After[:min(context, len(anchor.After))]...)
+	}
+
+	if fd := m.exportDiff(loc.File); fd != nil {
+		return diff.Snippet(fd.rows, loc.Side, loc.StartLine, loc.EndLine, context, markers)
+	}
+	return nil
+}
+
+// exportDiff returns the diff of a file for export, loading it if it hasn't been viewed yet
+func (m *model) exportDiff(file string) *fileDiff {
+	if fd, exists := m.diffs[file]; exists {
+		return fd
+	}
+	for i, f := range m.changedFiles {
+		if f.Path != file {
+			continue
+		}
+		fd, err := m.parseDiff(i)
+		if err != nil {
+			m.logger.Debug("failed to load diff for export", "file", file, "error", err)
+			return nil
+		}
+		return fd
+	}
+	return nil
+}
+
+// saveCommentsToFile saves all comments to a file
+func (m *model) saveCommentsToFile() error {
+	content, err := m.exportComments()
+	if err != nil {
+		return err
+	}
+	if content == "No comments to export." {
+		return fmt.Errorf("no comments to save")
+	}
+
+	exporter, err := m.export.exporter()
+	if err != nil {
+		return err
+	}
+	filename := fmt.Sprintf("code-review-comments-%s%s", time.Now().Format("20060102-150405"), exporter.Extension())
+
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+
+	m.statusMessage = fmt.Sprintf("💾 Saved to %s", filename)
+	return nil
+}
+
+// copyCommentsToClipboard copies all comments to the clipboard
+func (m *model) copyCommentsToClipboard() error {
+	content, err := m.exportComments()
+	if err != nil {
+		return err
+	}
+	if content == "No comments to export." {
+		return fmt.Errorf("no comments to copy")
+	}
+
+	if err := clipboard.WriteAll(content); err != nil {
+		return fmt.Errorf("failed to copy to clipboard: %w", err)
+	}
+
+	m.statusMessage = "📋 Copied to clipboard"
+	return nil
+}
@@ -0,0 +1,199 @@
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/samverrall/review-ui/internal/diff"
+	"github.com/samverrall/review-ui/internal/git"
+)
+
+// fileStat summarises a changed file for the file list
+type fileStat struct {
+	added    int  // Lines added
+	deleted  int  // Lines deleted
+	comments int  // Comments on the file
+	loaded   bool // Whether the diff could be loaded to count lines
+	binary   bool // Whether the file is binary, so has no line counts
+}
+
+// statusLabel returns a human readable status for a changed file
+func statusLabel(file git.ChangedFile) string {
+	switch file.Status() {
+	case git.StatusUntracked:
+		return "untracked"
+	case git.StatusUnmerged:
+		return "conflicted"
+	case git.StatusAdded:
+		return "added"
+	case git.StatusDeleted:
+		return "deleted"
+	case git.StatusRenamed:
+		return "renamed"
+	case git.StatusCopied:
+		return "copied"
+	default:
+		return "modified"
+	}
+}
+
+// fileStatusStyle returns the style used to display a file status label
+func fileStatusStyle(label string) lipgloss.Style {
+	switch label {
+	case "added", "untracked":
+		return statusAddedStyle
+	case "deleted":
+		return statusDeletedStyle
+	case "renamed", "copied":
+		return statusRenamedStyle
+	case "conflicted":
+		return statusConflictStyle
+	default:
+		return statusModifiedStyle
+	}
+}
+
+// fileStatsMsg carries the line counts of files loaded in the background by loadFileStats
+type fileStatsMsg struct {
+	stats      map[string]fileStat // Line counts by path
+	generation int                 // statsGeneration when loading started
+}
+
+// loadFileStats returns a command that diffs every changed file without a loaded diff or
+// cached counts, so the file list can show line counts without blocking the UI. Files whose
+// diff can't be loaded are logged and shown without counts. It returns nil if there's nothing to load.
+func (m *model) loadFileStats() tea.Cmd {
+	var files []git.ChangedFile
+	for _, file := range m.changedFiles {
+		_, loaded := m.diffs[file.Path]
+		_, cached := m.fileStats[file.Path]
+		if !loaded && !cached {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	client, mode, logger, generation := m.gitClient, m.mode, m.logger, m.statsGeneration
+	return func() tea.Msg {
+		stats := make(map[string]fileStat)
+		for _, file := range files {
+			raw, err := client.GetFileDiff(mode, file)
+			if err != nil {
+				logger.Debug("failed to load diff for stats", "file", file.Path, "error", err)
+				continue
+			}
+			files, err := diff.Parse(raw)
+			if err != nil {
+				logger.Debug("failed to parse diff for stats", "file", file.Path, "error", err)
+				continue
+			}
+			stats[file.Path] = diffStat(files)
+		}
+		return fileStatsMsg{stats: stats, generation: generation}
+	}
+}
+
+// handleFileStats caches line counts loaded in the background, unless the changes were
+// reloaded since they were requested
+func (m *model) handleFileStats(msg fileStatsMsg) {
+	if msg.generation != m.statsGeneration {
+		return
+	}
+	if m.fileStats == nil {
+		m.fileStats = make(map[string]fileStat)
+	}
+	for path, stat := range msg.stats {
+		m.fileStats[path] = stat
+	}
+}
+
+// clearFileStats discards the cached line counts, e.g. after the changes are reloaded
+func (m *model) clearFileStats() {
+	m.fileStats = nil
+	m.statsGeneration++
+}
+
+// fileStat returns the diffstat and comment count for the file at the given index
+func (m *model) fileStat(index int) fileStat {
+	file := m.changedFiles[index]
+	var stat fileStat
+	if fd, exists := m.diffs[file.Path]; exists {
+		stat = diffStat(fd.files)
+	} else if cached, exists := m.fileStats[file.Path]; exists {
+		stat = cached
+	}
+	stat.comments = m.commentCount(file.Path)
+	return stat
+}
+
+// diffStat counts the lines added and deleted in a parsed diff
+func diffStat(files []*diff.File) fileStat {
+	stat := fileStat{loaded: true}
+	for _, f := range files {
+		stat.binary = stat.binary || f.IsBinary
+		added, deleted := f.Stats()
+		stat.added += added
+		stat.deleted += deleted
+	}
+	return stat
+}
+
+// commentCount returns the number of comments on a file
+func (m *model) commentCount(filename string) int {
+	count := 0
+	for loc, comments := range m.comments {
+		if loc.File == filename {
+			count += len(comments)
+		}
+	}
+	return count
+}
+
+// fileListSummary returns the totals line shown at the top of the file list
+func (m *model) fileListSummary() string {
+	var added, deleted, comments int
+	statusCounts := make(map[string]int)
+	for i, file := range m.changedFiles {
+		stat := m.fileStat(i)
+		added += stat.added
+		deleted += stat.deleted
+		comments += stat.comments
+		statusCounts[statusLabel(file)]++
+	}
+
+	summary := fmt.Sprintf("%d files · +%d -%d · %d comments", len(m.changedFiles), added, deleted, comments)
+	if reviewed := m.reviewedCount(); reviewed > 0 {
+		summary += fmt.Sprintf(" · %d/%d reviewed", reviewed, len(m.changedFiles))
+	}
+
+	// Status breakdown in a fixed order
+	breakdown := ""
+	for _, label := range []string{"added", "modified", "deleted", "renamed", "copied", "untracked", "conflicted"} {
+		if count := statusCounts[label]; count > 0 {
+			if breakdown != "" {
+				breakdown += ", "
+			}
+			breakdown += fmt.Sprintf("%d %s", count, label)
+		}
+	}
+	if breakdown != "" {
+		summary += " (" + breakdown + ")"
+	}
+
+	return summary
+}
+
+// formatDiffStat formats line counts as "+12 -3", or "binary"/"?" when there are none to show
+func formatDiffStat(stat fileStat) string {
+	if !stat.loaded {
+		return "?"
+	}
+	if stat.binary {
+		return "binary"
+	}
+	return fmt.Sprintf("+%d -%d", stat.added, stat.deleted)
+}
@@ -0,0 +1,183 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/samverrall/review-ui/internal/git"
+)
+
+// fileTreeNode is a directory or file in the file picker tree
+type fileTreeNode struct {
+	name      string          // Display name, may span several directories when compacted (e.g. "internal/ui")
+	path      string          // Full directory path, or the file path for files
+	fileIndex int             // Index into changedFiles, -1 for directories
+	children  []*fileTreeNode // Sorted children of a directory
+}
+
+// isDir reports whether the node is a directory
+func (n *fileTreeNode) isDir() bool {
+	return n.fileIndex < 0
+}
+
+// fileListEntry is a visible row of the file picker
+type fileListEntry struct {
+	node    *fileTreeNode
+	depth   int
+	matches []int // Rune positions in node.name matched by the fuzzy filter
+}
+
+// comparePaths orders paths the way the tree displays them: directory by directory,
+// with subdirectories before files at each level
+func comparePaths(a, b string) bool {
+	aParts, bParts := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		if aParts[i] == bParts[i] {
+			continue
+		}
+		aIsDir, bIsDir := i < len(aParts)-1, i < len(bParts)-1
+		if aIsDir != bIsDir {
+			return aIsDir
+		}
+		return aParts[i] < bParts[i]
+	}
+	return len(aParts) < len(bParts)
+}
+
+// sortChangedFiles sorts files into tree order so next/previous navigation matches the picker
+func sortChangedFiles(files []git.ChangedFile) {
+	sort.SliceStable(files, func(i, j int) bool {
+		return comparePaths(files[i].Path, files[j].Path)
+	})
+}
+
+// buildFileTree builds a directory tree from the changed files, which must be in tree order
+func buildFileTree(files []git.ChangedFile) *fileTreeNode {
+	root := &fileTreeNode{fileIndex: -1}
+
+	for i, file := range files {
+		parts := strings.Split(file.Path, "/")
+		node := root
+		for depth, part := range parts[:len(parts)-1] {
+			var child *fileTreeNode
+			if last := len(node.children) - 1; last >= 0 && node.children[last].isDir() && node.children[last].name == part {
+				child = node.children[last]
+			} else {
+				child = &fileTreeNode{name: part, path: strings.Join(parts[:depth+1], "/"), fileIndex: -1}
+				node.children = append(node.children, child)
+			}
+			node = child
+		}
+		node.children = append(node.children, &fileTreeNode{name: parts[len(parts)-1], path: file.Path, fileIndex: i})
+	}
+
+	compactFileTree(root)
+	return root
+}
+
+// compactFileTree merges directories that only contain a single subdirectory, so deep
+// paths like "internal/ui" take one row instead of two
+func compactFileTree(node *fileTreeNode) {
+	for _, child := range node.children {
+		for child.isDir() && len(child.children) == 1 && child.children[0].isDir() {
+			grandchild := child.children[0]
+			child.name = child.name + "/" + grandchild.name
+			child.path = grandchild.path
+			child.children = grandchild.children
+		}
+		compactFileTree(child)
+	}
+}
+
+// fileListEntries returns the visible rows of the file picker, skipping collapsed directories.
+// While the fuzzy filter has a query, matching files are listed flat, best match first.
+func (m *model) fileListEntries() []fileListEntry {
+	if query := m.fileFilter.Value(); m.fileFilterMode && query != "" {
+		return m.filteredFileListEntries(query)
+	}
+
+	var entries []fileListEntry
+	var walk func(node *fileTreeNode, depth int)
+	walk = func(node *fileTreeNode, depth int) {
+		for _, child := range node.children {
+			entries = append(entries, fileListEntry{node: child, depth: depth})
+			if child.isDir() && !m.collapsedDirs[child.path] {
+				walk(child, depth+1)
+			}
+		}
+	}
+	walk(buildFileTree(m.changedFiles), 0)
+	return entries
+}
+
+// fileListIndexOf returns the visible row of a file, expanding its parent directories if needed
+func (m *model) fileListIndexOf(fileIndex int) int {
+	if fileIndex < 0 || fileIndex >= len(m.changedFiles) {
+		return 0
+	}
+	path := m.changedFiles[fileIndex].Path
+	for dir := range m.collapsedDirs {
+		if strings.HasPrefix(path, dir+"/") {
+			delete(m.collapsedDirs, dir)
+		}
+	}
+	for i, entry := range m.fileListEntries() {
+		if entry.node.fileIndex == fileIndex {
+			return i
+		}
+	}
+	return 0
+}
+
+// dirStat aggregates the stats of every file below a directory
+func (m *model) dirStat(node *fileTreeNode) (stat fileStat, files int) {
+	stat.loaded = true
+	var walk func(node *fileTreeNode)
+	walk = func(node *fileTreeNode) {
+		for _, child := range node.children {
+			if child.isDir() {
+				walk(child)
+				continue
+			}
+			files++
+			childStat := m.fileStat(child.fileIndex)
+			stat.added += childStat.added
+			stat.deleted += childStat.deleted
+			stat.comments += childStat.comments
+			stat.loaded = stat.loaded && childStat.loaded
+		}
+	}
+	walk(node)
+	return stat, files
+}
+
+// filteredFileListEntries returns the files matching a fuzzy query, ordered by score then path
+func (m *model) filteredFileListEntries(query string) []fileListEntry {
+	type scoredEntry struct {
+		entry fileListEntry
+		score int
+	}
+
+	var scored []scoredEntry
+	for i, file := range m.changedFiles {
+		score, positions, ok := fuzzyMatch(query, file.Path)
+		if !ok {
+			continue
+		}
+		node := &fileTreeNode{name: file.Path, path: file.Path, fileIndex: i}
+		scored = append(scored, scoredEntry{entry: fileListEntry{node: node, matches: positions}, score: score})
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].score != scored[j].score {
+			return scored[i].score > scored[j].score
+		}
+		return comparePaths(scored[i].entry.node.path, scored[j].entry.node.path)
+	})
+
+	entries := make([]fileListEntry, len(scored))
+	for i, s := range scored {
+		entries[i] = s.entry
+	}
+	return entries
+}
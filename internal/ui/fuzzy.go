@@ -0,0 +1,81 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Scores used by fuzzyMatch. Matches at the start of a path segment or word and runs of
+// consecutive characters are preferred, as are matches in the file name over the directory.
+const (
+	fuzzyMatchScore       = 16
+	fuzzyConsecutiveBonus = 24
+	fuzzyBoundaryBonus    = 20
+	fuzzyBasenameBonus    = 8
+	fuzzyGapPenalty       = 1
+)
+
+// fuzzyMatch reports whether every rune of pattern appears in text in order, ignoring case.
+// It returns a score (higher is better) and the rune positions of the matched characters.
+func fuzzyMatch(pattern, text string) (int, []int, bool) {
+	if pattern == "" {
+		return 0, nil, true
+	}
+
+	patternRunes := []rune(strings.ToLower(pattern))
+	textRunes := []rune(text)
+	basenameStart := strings.LastIndex(text, "/") + 1
+	basenameStart = len([]rune(text[:basenameStart]))
+
+	// Prefer a match entirely within the file name, falling back to the whole path
+	if score, positions, ok := fuzzyMatchFrom(patternRunes, textRunes, basenameStart); ok {
+		return score + fuzzyBasenameBonus*len(patternRunes), positions, true
+	}
+	return fuzzyMatchFrom(patternRunes, textRunes, 0)
+}
+
+// fuzzyMatchFrom greedily matches pattern against text starting at the given rune offset
+func fuzzyMatchFrom(pattern, text []rune, start int) (int, []int, bool) {
+	positions := make([]int, 0, len(pattern))
+	score := 0
+	p := 0
+
+	for i := start; i < len(text) && p < len(pattern); i++ {
+		if unicode.ToLower(text[i]) != pattern[p] {
+			continue
+		}
+
+		score += fuzzyMatchScore
+		if len(positions) > 0 {
+			if last := positions[len(positions)-1]; last == i-1 {
+				score += fuzzyConsecutiveBonus
+			} else {
+				score -= fuzzyGapPenalty * (i - last - 1)
+			}
+		}
+		if isFuzzyBoundary(text, i) {
+			score += fuzzyBoundaryBonus
+		}
+
+		positions = append(positions, i)
+		p++
+	}
+
+	if p < len(pattern) {
+		return 0, nil, false
+	}
+	return score, positions, true
+}
+
+// isFuzzyBoundary reports whether the rune at i starts a path segment, word or camelCase hump
+func isFuzzyBoundary(text []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := text[i-1]
+	switch prev {
+	case '/', '_', '-', '.', ' ':
+		return true
+	}
+	return unicode.IsLower(prev) && unicode.IsUpper(text[i])
+}
@@ -0,0 +1,176 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/samverrall/review-ui/internal/diff"
+)
+
+// reviewCommand is a change to the review that can be undone. Commands are kept in the
+// model's history in the order they were done, so they can be undone, redone or replayed
+// onto another model.
+type reviewCommand interface {
+	do(m *model)
+	undo(m *model)
+	describe() string // Short description for the status bar, e.g. "add comment"
+}
+
+// execute does a command and records it in the history, discarding any undone commands
+func (m *model) execute(cmd reviewCommand) {
+	cmd.do(m)
+	m.history = append(m.history[:m.historyPos], cmd)
+	m.historyPos = len(m.history)
+	m.saveSession()
+}
+
+// undo reverts the last command that's done
+func (m *model) undo() {
+	if m.historyPos == 0 {
+		m.statusMessage = "Nothing to undo"
+		return
+	}
+	m.historyPos--
+	cmd := m.history[m.historyPos]
+	cmd.undo(m)
+	m.statusMessage = fmt.Sprintf("↶ Undid %s", cmd.describe())
+	m.saveSession()
+}
+
+// redo does the last undone command again
+func (m *model) redo() {
+	if m.historyPos == len(m.history) {
+		m.statusMessage = "Nothing to redo"
+		return
+	}
+	cmd := m.history[m.historyPos]
+	m.historyPos++
+	cmd.do(m)
+	m.statusMessage = fmt.Sprintf("↷ Redid %s", cmd.describe())
+	m.saveSession()
+}
+
+// savedComment is a comment removed from the review along with everything needed to put it back
+type savedComment struct {
+	loc      commentLocation
+	comment  comment
+	index    int          // Position among the comments at loc
+	anchor   *diff.Anchor // Code the location was written against, nil if not captured
+	outdated bool
+}
+
+// insertComment puts a comment back where it was removed from
+func (m *model) insertComment(s savedComment) {
+	comments := m.comments[s.loc]
+	index := min(s.index, len(comments))
+	m.comments[s.loc] = append(comments[:index:index], append([]comment{s.comment}, comments[index:]...)...)
+	if _, exists := m.anchors[s.loc]; !exists && s.anchor != nil {
+		m.anchors[s.loc] = *s.anchor
+	}
+	if s.outdated {
+		m.outdated[s.loc] = true
+	}
+}
+
+// removeComment removes a comment by ID, forgetting its location once no comments are left on it
+func (m *model) removeComment(id int) (savedComment, bool) {
+	c, loc, ok := m.findComment(id)
+	if !ok {
+		return savedComment{}, false
+	}
+
+	s := savedComment{loc: loc, comment: c, outdated: m.outdated[loc]}
+	if anchor, exists := m.anchors[loc]; exists {
+		s.anchor = &anchor
+	}
+	comments := m.comments[loc]
+	for i := range comments {
+		if comments[i].ID == id {
+			s.index = i
+		}
+	}
+
+	comments = append(comments[:s.index:s.index], comments[s.index+1:]...)
+	if len(comments) == 0 {
+		delete(m.comments, loc)
+		delete(m.anchors, loc)
+		delete(m.outdated, loc)
+	} else {
+		m.comments[loc] = comments
+	}
+	return s, true
+}
+
+// addCommentCommand adds a comment
+type addCommentCommand struct {
+	saved savedComment
+}
+
+func (c *addCommentCommand) do(m *model) {
+	if c.saved.anchor == nil {
+		// Capture the code the comment is written against the first time it's added
+		if fd, exists := m.diffs[c.saved.loc.File]; exists {
+			anchor := diff.CaptureAnchor(fd.rows, c.saved.loc.Side, c.saved.loc.StartLine, c.saved.loc.EndLine)
+			c.saved.anchor = &anchor
+		}
+	}
+	m.insertComment(c.saved)
+}
+
+func (c *addCommentCommand) undo(m *model) {
+	// The comment may have been re-anchored since, so remember where it is now for redo
+	if saved, ok := m.removeComment(c.saved.comment.ID); ok {
+		c.saved = saved
+	}
+}
+
+func (c *addCommentCommand) describe() string { return "add comment" }
+
+// editCommentCommand replaces the body and severity of a comment
+type editCommentCommand struct {
+	before, after comment
+}
+
+func (c *editCommentCommand) do(m *model)   { m.replaceComment(c.after) }
+func (c *editCommentCommand) undo(m *model) { m.replaceComment(c.before) }
+
+func (c *editCommentCommand) describe() string { return "edit comment" }
+
+// deleteCommentCommand deletes comments, such as a thread and its replies
+type deleteCommentCommand struct {
+	ids   []int
+	saved []savedComment // The deleted comments, in the order they were removed
+}
+
+func (c *deleteCommentCommand) do(m *model) {
+	c.saved = nil
+	for _, id := range c.ids {
+		if saved, ok := m.removeComment(id); ok {
+			c.saved = append(c.saved, saved)
+		}
+	}
+}
+
+func (c *deleteCommentCommand) undo(m *model) {
+	// Put the comments back in reverse, so each returns to the position it was removed from
+	for i := len(c.saved) - 1; i >= 0; i-- {
+		m.insertComment(c.saved[i])
+	}
+}
+
+func (c *deleteCommentCommand) describe() string { return "delete comment" }
+
+// reviewedCommand marks or unmarks a file as reviewed
+type reviewedCommand struct {
+	file     string
+	reviewed bool
+}
+
+func (c *reviewedCommand) do(m *model)   { m.setReviewed(c.file, c.reviewed) }
+func (c *reviewedCommand) undo(m *model) { m.setReviewed(c.file, !c.reviewed) }
+
+func (c *reviewedCommand) describe() string {
+	if c.reviewed {
+		return fmt.Sprintf("mark %s as reviewed", c.file)
+	}
+	return fmt.Sprintf("unmark %s", c.file)
+}
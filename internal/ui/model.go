@@ -4,42 +4,170 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"os"
 	"sort"
-	"strings"
 	"time"
 
-	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 
 	"github.com/samverrall/review-ui/internal/diff"
+	"github.com/samverrall/review-ui/internal/export"
 	"github.com/samverrall/review-ui/internal/git"
+	"github.com/samverrall/review-ui/internal/session"
 )
 
 type model struct {
-	gitClient      git.GitClient       // Git client for operations
-	changedFiles   []string            // All changed files
-	currentIndex   int                 // Current file index
-	diffs          map[string]string   // Cached formatted diffs
-	viewport       viewport.Model      // Scrollable viewport
-	ready          bool                // Terminal size known
-	width          int                 // Terminal width
-	height         int                 // Terminal height
-	err            error               // Error state
-	cursorLine     int                 // Current cursor line position
-	commentInput   textinput.Model     // Text input for comments
-	commentMode    bool                // Whether we're in comment input mode
-	comments       map[string][]string // Comments by "filename:lineNumber" or "filename:startLine-endLine"
-	commentLine    int                 // Line number where comment is being added (or start of range)
-	commentEndLine int                 // End line of comment range (-1 for single line)
-	selectionMode  bool                // Whether we're in visual selection mode
-	selectionStart int                 // Start line of selection
-	statusMessage  string              // Status message to display to user
-	fileListMode   bool                // Whether we're in file list selection mode
-	fileListCursor int                 // Current cursor position in file list
-	logger         *slog.Logger        // Logger for debug output
+	gitClient       git.GitClient                   // Git client for operations
+	mode            git.DiffMode                    // Which changes are being reviewed
+	changedFiles    []git.ChangedFile               // All changed files
+	currentIndex    int                             // Current file index
+	diffs           map[string]*fileDiff            // Cached parsed and formatted diffs
+	viewport        viewport.Model                  // Scrollable viewport
+	ready           bool                            // Terminal size known
+	width           int                             // Terminal width
+	height          int                             // Terminal height
+	err             error                           // Error state
+	cursorLine      int                             // Current cursor line position
+	splitView       bool                            // Whether the diff is shown side-by-side
+	cursorSide      diff.Side                       // Column the cursor is on in the side-by-side view
+	commentInput    textarea.Model                  // Multi-line editor for comments
+	commentMode     bool                            // Whether we're in comment input mode
+	comments        map[commentLocation][]comment   // Comments by file, side and real line range
+	nextCommentID   int                             // ID given to the next comment added
+	anchors         map[commentLocation]diff.Anchor // Code each comment location was written against
+	outdated        map[commentLocation]bool        // Comment locations whose code is no longer in the diff
+	commentTarget   commentLocation                 // Location the comment being entered will attach to
+	editingComment  int                             // ID of the comment being edited, 0 when adding a new comment
+	commentFocus    int                             // Which of the comments under the cursor e and d act on
+	confirmDelete   int                             // ID of the comment waiting for delete confirmation, 0 if none
+	commentSeverity severity                        // Severity picked for the comment being written
+	replyingTo      int                             // ID of the thread root being replied to, 0 when not replying
+	hideResolved    bool                            // Whether resolved threads are hidden
+	export          exportOptions                   // Which comments are exported and how
+	history         []reviewCommand                 // Changes to the review in the order they were done
+	historyPos      int                             // Number of commands in history that are done, the rest can be redone
+	selectionMode   bool                            // Whether we're in visual selection mode
+	selectionStart  int                             // Start line of selection
+	statusMessage   string                          // Status message to display to user
+	fileListMode    bool                            // Whether we're in file list selection mode
+	fileListCursor  int                             // Current cursor position in file list
+	collapsedDirs   map[string]bool                 // Directories collapsed in the file list tree
+	fileFilterMode  bool                            // Whether the file list fuzzy filter is open
+	fileFilter      textinput.Model                 // Text input for the file list fuzzy filter
+	reviewed        map[string]bool                 // Files marked as reviewed
+	repo            git.RepoInfo                    // Repository details used to key saved sessions
+	sessions        *session.Store                  // Saved review sessions, nil if they're disabled
+	pendingSession  *session.Session                // Saved review waiting for the user to resume or discard it
+	watchInterval   time.Duration                   // How often to check for changes on disk, 0 if not watching
+	fingerprint     string                          // Fingerprint of the reviewed changes when last loaded
+	fileStats       map[string]fileStat             // Line counts of files whose diff isn't loaded, for the file list
+	statsGeneration int                             // Bumped when fileStats is discarded, so stats loaded before are ignored
+	logger          *slog.Logger                    // Logger for debug output
+}
+
+// fileDiff caches the parsed diff of a single file alongside its rendered form
+type fileDiff struct {
+	files          []*diff.File    // Parsed diff, normally a single file
+	rows           []diff.Row      // Unified display rows, one per viewport line
+	splitRows      []diff.SplitRow // Side-by-side display rows, one per viewport line
+	rendered       string          // Unified rows formatted with colors and syntax highlighting
+	formatted      bool            // Whether rendered has been computed
+	splitRendered  string          // Side-by-side rows formatted for display
+	splitFormatted bool            // Whether splitRendered has been computed
+}
+
+// commentLocation anchors a comment to real line numbers on one side of a file,
+// rather than to rows of the rendered diff
+type commentLocation struct {
+	File      string    // File the comment belongs to
+	Side      diff.Side // Whether the line numbers refer to the old or new file
+	StartLine int       // First line of the comment (1-indexed)
+	EndLine   int       // Last line of the comment, equal to StartLine for single lines
+}
+
+// comment is a review comment. IDs are stable for the whole review, so a comment can be
+// found again after it's moved by re-anchoring.
+type comment struct {
+	ID        int       // Unique identifier, starting at 1
+	Body      string    // Comment text
+	Severity  severity  // Category of the comment, severityNone if untagged
+	ReplyTo   int       // ID of the root comment of the thread this replies to, 0 for a root comment
+	Author    string    // Who wrote the comment
+	CreatedAt time.Time // When the comment was written
+	Resolved  bool      // Whether the thread is resolved, only set on root comments
+}
+
+// Size of the comment editor; longer comments scroll within it
+const (
+	commentInputWidth  = 76
+	commentInputHeight = 5
+)
+
+// newCommentInput creates the comment editor, sized to fit inside commentInputStyle.
+// Comments have no length limit, as feedback to an agent is often long.
+func newCommentInput() textarea.Model {
+	ta := textarea.New()
+	ta.Placeholder = "Enter your comment... (markdown supported)"
+	ta.ShowLineNumbers = false
+	ta.CharLimit = 0
+	ta.MaxHeight = 0
+	ta.SetWidth(commentInputWidth)
+	ta.SetHeight(commentInputHeight)
+	return ta
+}
+
+// summaryLocation is where the review summary is kept, as the only comment not on a file
+var summaryLocation = commentLocation{}
+
+// fileLocation returns the location of comments on a whole file rather than some of its lines
+func fileLocation(file string) commentLocation {
+	return commentLocation{File: file}
+}
+
+// isSummary reports whether the location holds the review summary
+func (l commentLocation) isSummary() bool {
+	return l.File == ""
+}
+
+// isFileLevel reports whether the location is a whole file rather than some of its lines
+func (l commentLocation) isFileLevel() bool {
+	return l.File != "" && l.StartLine == 0
+}
+
+// isRange reports whether the location spans more than one line
+func (l commentLocation) isRange() bool {
+	return l.EndLine != l.StartLine
+}
+
+// label formats the location for display, e.g. "line 12 (new)" or "lines 3-5 (old)"
+func (l commentLocation) label() string {
+	if l.isSummary() {
+		return "review summary"
+	}
+	if l.isFileLevel() {
+		return "whole file"
+	}
+	if l.isRange() {
+		return fmt.Sprintf("lines %d-%d (%s)", l.StartLine, l.EndLine, l.Side)
+	}
+	return fmt.Sprintf("line %d (%s)", l.StartLine, l.Side)
+}
+
+// Options configures how the review UI is created
+type Options struct {
+	Mode          git.DiffMode  // Which changes to review, defaults to the working tree
+	WatchInterval time.Duration // How often to check for changes on disk, 0 disables watching
+
+	ExportFormat     string   // Format comments are exported in, "markdown" (the default), "json" or "sarif"
+	ExportSeverities []string // Only export comments with these severities, e.g. "blocking", all comments if empty
+	GroupBySeverity  bool     // Group exported comments by severity, blocking first
+
+	ExportCode        bool // Include the commented code with each exported comment
+	ExportContext     int  // Lines of surrounding code to include with the commented code
+	ExportDiffMarkers bool // Keep the +/- markers on exported code, so it reads as a diff
 }
 
 // New creates and initializes a new model with the default git client and no logging
@@ -50,17 +178,43 @@ func New() (model, error) {
 
 // NewWithLogger creates and initializes a new model with the default git client and custom logger
 func NewWithLogger(logger *slog.Logger) (model, error) {
-	return newWithGitClientAndLogger(&realGitClient{}, logger)
+	return NewWithOptions(Options{}, logger)
+}
+
+// NewWithOptions creates and initializes a new model with the default git client, custom options and logger
+func NewWithOptions(opts Options, logger *slog.Logger) (model, error) {
+	return newWithGitClientAndLogger(&realGitClient{}, opts, logger)
 }
 
 // NewWithGitClient creates and initializes a new model with a custom git client
 func NewWithGitClient(gitClient git.GitClient) (model, error) {
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-	return newWithGitClientAndLogger(gitClient, logger)
+	return newWithGitClientAndLogger(gitClient, Options{}, logger)
 }
 
-// newWithGitClientAndLogger creates and initializes a new model with a custom git client and logger
-func newWithGitClientAndLogger(gitClient git.GitClient, logger *slog.Logger) (model, error) {
+// newWithGitClientAndLogger creates and initializes a new model with a custom git client, options and logger
+func newWithGitClientAndLogger(gitClient git.GitClient, opts Options, logger *slog.Logger) (model, error) {
+	exportOpts := exportOptions{
+		format:          export.Format(opts.ExportFormat),
+		groupBySeverity: opts.GroupBySeverity,
+		code:            opts.ExportCode,
+		contextLines:    max(opts.ExportContext, 0),
+		diffMarkers:     opts.ExportDiffMarkers,
+	}
+	if _, err := exportOpts.exporter(); err != nil {
+		return model{}, err
+	}
+	for _, name := range opts.ExportSeverities {
+		sev, err := parseSeverity(name)
+		if err != nil {
+			return model{}, err
+		}
+		if exportOpts.severities == nil {
+			exportOpts.severities = make(map[severity]bool)
+		}
+		exportOpts.severities[sev] = true
+	}
+
 	// Check if we're in a git repository
 	isRepo, err := gitClient.IsGitRepo()
 	if err != nil {
@@ -71,27 +225,45 @@ func newWithGitClientAndLogger(gitClient git.GitClient, logger *slog.Logger) (mo
 	}
 
 	// Get changed files
-	files, err := gitClient.GetChangedFiles()
+	files, err := gitClient.GetChangedFiles(opts.Mode)
 	if err != nil {
 		return model{}, fmt.Errorf("failed to get changed files: %w", err)
 	}
 
-	// Initialize comment input
-	ti := textinput.New()
-	ti.Placeholder = "Enter your comment..."
-	ti.CharLimit = 200
-	ti.Width = 80
+	// Keep a deterministic order that matches the file list tree
+	sortChangedFiles(files)
+
+	// Initialize file list filter input
+	filter := textinput.New()
+	filter.Prompt = "/ "
+	filter.Placeholder = "filter files..."
 
 	m := model{
-		gitClient:    gitClient,
-		changedFiles: files,
-		currentIndex: 0,
-		diffs:        make(map[string]string),
-		viewport:     viewport.New(0, 0),
-		commentInput: ti,
-		commentMode:  false,
-		comments:     make(map[string][]string),
-		logger:       logger,
+		gitClient:     gitClient,
+		mode:          opts.Mode,
+		changedFiles:  files,
+		currentIndex:  0,
+		diffs:         make(map[string]*fileDiff),
+		viewport:      viewport.New(0, 0),
+		commentInput:  newCommentInput(),
+		commentMode:   false,
+		comments:      make(map[commentLocation][]comment),
+		nextCommentID: 1,
+		anchors:       make(map[commentLocation]diff.Anchor),
+		outdated:      make(map[commentLocation]bool),
+		collapsedDirs: make(map[string]bool),
+		fileFilter:    filter,
+		reviewed:      make(map[string]bool),
+		watchInterval: opts.WatchInterval,
+		export:        exportOpts,
+		logger:        logger,
+	}
+
+	// Fingerprint the changes as loaded, so the watcher can tell when they change
+	if m.watchInterval > 0 {
+		if m.fingerprint, err = gitClient.GetFingerprint(opts.Mode); err != nil {
+			m.logger.Debug("failed to fingerprint changes", "error", err)
+		}
 	}
 
 	// Load first diff if we have files
@@ -101,6 +273,9 @@ func newWithGitClientAndLogger(gitClient git.GitClient, logger *slog.Logger) (mo
 		}
 	}
 
+	// Offer to resume a saved review of this branch
+	m.initSession()
+
 	return m, nil
 }
 
@@ -111,17 +286,29 @@ func (r *realGitClient) IsGitRepo() (bool, error) {
 	return git.IsGitRepo()
 }
 
-func (r *realGitClient) GetChangedFiles() ([]string, error) {
-	return git.GetChangedFiles()
+func (r *realGitClient) GetChangedFiles(mode git.DiffMode) ([]git.ChangedFile, error) {
+	return git.GetChangedFiles(mode)
+}
+
+func (r *realGitClient) GetFileDiff(mode git.DiffMode, file git.ChangedFile) (string, error) {
+	return git.GetFileDiff(mode, file)
+}
+
+func (r *realGitClient) GetRepoInfo() (git.RepoInfo, error) {
+	return git.GetRepoInfo()
+}
+
+func (r *realGitClient) GetFingerprint(mode git.DiffMode) (string, error) {
+	return git.GetFingerprint(mode)
 }
 
-func (r *realGitClient) GetFileDiff(filename string) (string, error) {
-	return git.GetFileDiff(filename)
+func (r *realGitClient) ResolveRevision(rev string) (string, error) {
+	return git.ResolveRevision(rev)
 }
 
-// Init initializes the model (required by Bubbletea)
+// Init initializes the model (required by Bubbletea), starting the file watcher if enabled
 func (m model) Init() tea.Cmd {
-	return nil
+	return m.watchFiles()
 }
 
 // Width returns the current terminal width
@@ -129,24 +316,113 @@ func (m model) Width() int {
 	return m.width
 }
 
-// getCommentKey generates a unique key for storing comments
-func (m *model) getCommentKey(lineNumber int) string {
+// currentFile returns the name of the file being reviewed, or "" if there is none
+func (m *model) currentFile() string {
 	if m.currentIndex < 0 || m.currentIndex >= len(m.changedFiles) {
 		return ""
 	}
-	return fmt.Sprintf("%s:%d", m.changedFiles[m.currentIndex], lineNumber)
+	return m.changedFiles[m.currentIndex].Path
 }
 
-// getCommentKeyForRange generates a unique key for storing range comments
-func (m *model) getCommentKeyForRange(startLine, endLine int) string {
-	if m.currentIndex < 0 || m.currentIndex >= len(m.changedFiles) {
-		return ""
+// lineNumber returns the file line number shown on a viewport row of the current diff for
+// the given side, or 0 if the row has no line on that side (headers, hunk headers, gaps)
+func (m *model) lineNumber(row int, side diff.Side) int {
+	fd, exists := m.diffs[m.currentFile()]
+	if !exists || row < 0 {
+		return 0
+	}
+	if m.splitView {
+		if row >= len(fd.splitRows) {
+			return 0
+		}
+		return fd.splitRows[row].Number(side)
+	}
+	if row >= len(fd.rows) {
+		return 0
+	}
+	return fd.rows[row].Number(side)
+}
+
+// rowCount returns the number of viewport rows of the current diff in the active layout
+func (m *model) rowCount() int {
+	fd, exists := m.diffs[m.currentFile()]
+	if !exists {
+		return 0
+	}
+	if m.splitView {
+		return len(fd.splitRows)
+	}
+	return len(fd.rows)
+}
+
+// commentSides returns the sides a comment may anchor to, in order of preference.
+// The side-by-side view anchors to whichever column the cursor is on.
+func (m *model) commentSides() []diff.Side {
+	if m.splitView {
+		return []diff.Side{m.cursorSide}
+	}
+	return []diff.Side{diff.SideNew, diff.SideOld}
+}
+
+// getCommentKey returns the comment location for a single row of the current diff.
+// Rows that don't correspond to a file line (headers, hunk headers) can't be commented on.
+func (m *model) getCommentKey(row int) (commentLocation, bool) {
+	return m.getCommentKeyForRange(row, row)
+}
+
+// getCommentKeyForRange returns the comment location covering a range of rows.
+// In the unified view, ranges that include any new-side line anchor to the new file,
+// otherwise to the old file.
+func (m *model) getCommentKeyForRange(startRow, endRow int) (commentLocation, bool) {
+	file := m.currentFile()
+	if file == "" {
+		return commentLocation{}, false
 	}
 	// Ensure start <= end
-	if startLine > endLine {
-		startLine, endLine = endLine, startLine
+	if startRow > endRow {
+		startRow, endRow = endRow, startRow
+	}
+
+	for _, side := range m.commentSides() {
+		loc := commentLocation{File: file, Side: side}
+		for row := startRow; row <= endRow; row++ {
+			line := m.lineNumber(row, side)
+			if line == 0 {
+				continue
+			}
+			if loc.StartLine == 0 {
+				loc.StartLine = line
+			}
+			loc.EndLine = line
+		}
+		if loc.StartLine != 0 {
+			return loc, true
+		}
+	}
+
+	return commentLocation{}, false
+}
+
+// sortedCommentLocations returns all comment locations ordered by file, line and side
+func (m *model) sortedCommentLocations() []commentLocation {
+	locations := make([]commentLocation, 0, len(m.comments))
+	for loc := range m.comments {
+		locations = append(locations, loc)
 	}
-	return fmt.Sprintf("%s:%d-%d", m.changedFiles[m.currentIndex], startLine, endLine)
+	sort.Slice(locations, func(i, j int) bool {
+		a, b := locations[i], locations[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.StartLine != b.StartLine {
+			return a.StartLine < b.StartLine
+		}
+		if a.EndLine != b.EndLine {
+			return a.EndLine < b.EndLine
+		}
+		return a.Side < b.Side
+	})
+	return locations
 }
 
 // getSelectionRange returns the start and end lines of the current selection (ordered)
@@ -158,34 +434,19 @@ func (m *model) getSelectionRange() (int, int) {
 	return start, end
 }
 
-// loadDiff loads and caches the diff for the file at the given index
+// loadDiff loads and caches the diff for the file at the given index and shows it in the viewport
 func (m *model) loadDiff(index int) error {
 	if index < 0 || index >= len(m.changedFiles) {
 		return nil
 	}
 
-	filename := m.changedFiles[index]
-
-	// Check if already cached
-	if _, exists := m.diffs[filename]; exists {
-		m.viewport.SetContent(m.diffs[filename])
-		m.cursorLine = 0
-		m.selectionMode = false
-		return nil
-	}
-
-	// Fetch diff from git
-	rawDiff, err := m.gitClient.GetFileDiff(filename)
+	fd, err := m.parseDiff(index)
 	if err != nil {
-		return fmt.Errorf("failed to load diff for %s: %w", filename, err)
+		return err
 	}
 
-	// Format the diff with colors
-	formattedDiff := diff.FormatDiff(m.width, rawDiff, m.logger)
-	m.diffs[filename] = formattedDiff
-
 	// Update viewport content
-	m.viewport.SetContent(formattedDiff)
+	m.setViewportContent(fd)
 	m.viewport.GotoTop()
 	m.cursorLine = 0
 	m.selectionMode = false
@@ -193,101 +454,146 @@ func (m *model) loadDiff(index int) error {
 	return nil
 }
 
-// exportComments formats all comments for export
-func (m *model) exportComments() string {
-	if len(m.comments) == 0 {
-		return "No comments to export."
-	}
+// contentWidth returns the width available to diff rows, after the cursor margin
+func (m *model) contentWidth() int {
+	return max(m.width-cursorMarkerWidth, 0)
+}
 
-	var builder strings.Builder
-	builder.WriteString("# Code Review Comments\n")
-	builder.WriteString(fmt.Sprintf("# Generated: %s\n\n", time.Now().Format("2006-01-02 15:04:05")))
+// setViewportContent shows a diff in the viewport using the active layout, formatting it
+// with colors the first time it's shown in that layout
+func (m *model) setViewportContent(fd *fileDiff) {
+	if m.splitView {
+		if !fd.splitFormatted {
+			fd.splitRendered = diff.RenderSplit(m.contentWidth(), fd.splitRows, m.logger)
+			fd.splitFormatted = true
+		}
+		m.viewport.SetContent(fd.splitRendered)
+		return
+	}
 
-	// Sort keys for consistent output
-	keys := make([]string, 0, len(m.comments))
-	for key := range m.comments {
-		keys = append(keys, key)
+	if !fd.formatted {
+		fd.rendered = diff.Render(m.contentWidth(), fd.rows, m.logger)
+		fd.formatted = true
 	}
-	sort.Strings(keys)
+	m.viewport.SetContent(fd.rendered)
+}
 
-	// Group by file
-	currentFile := ""
-	for _, key := range keys {
-		comments := m.comments[key]
+// rerenderDiffs discards formatted diffs, e.g. after a resize, and redraws the current one
+// without moving the cursor
+func (m *model) rerenderDiffs() {
+	for _, fd := range m.diffs {
+		fd.formatted = false
+		fd.splitFormatted = false
+	}
+	if fd, exists := m.diffs[m.currentFile()]; exists {
+		m.setViewportContent(fd)
+	}
+}
 
-		// Parse the key: "filename:lineNumber" or "filename:start-end"
-		parts := strings.SplitN(key, ":", 2)
-		if len(parts) != 2 {
-			continue
-		}
+// toggleSplitView switches between the unified and side-by-side layouts, keeping the
+// cursor on the same file line
+func (m *model) toggleSplitView() {
+	fd, exists := m.diffs[m.currentFile()]
+	if !exists {
+		m.splitView = !m.splitView
+		return
+	}
 
-		filename := parts[0]
-		location := parts[1]
+	// Remember the line under the cursor, preferring the column the cursor is on
+	side := m.cursorSide
+	if !m.splitView {
+		side = diff.SideNew
+	}
+	line := m.lineNumber(m.cursorLine, side)
+	if line == 0 {
+		side = 1 - side
+		line = m.lineNumber(m.cursorLine, side)
+	}
 
-		// Add file header if we've moved to a new file
-		if filename != currentFile {
-			if currentFile != "" {
-				builder.WriteString("\n")
+	m.splitView = !m.splitView
+	m.selectionMode = false
+	m.cursorSide = side
+	m.cursorLine = 0
+	if line != 0 {
+		for row := 0; row < m.rowCount(); row++ {
+			if m.lineNumber(row, side) == line {
+				m.cursorLine = row
+				break
 			}
-			builder.WriteString(fmt.Sprintf("## File: %s\n\n", filename))
-			currentFile = filename
 		}
+	}
 
-		// Format the location
-		var locationStr string
-		if strings.Contains(location, "-") {
-			// Range comment
-			var start, end int
-			if _, err := fmt.Sscanf(location, "%d-%d", &start, &end); err == nil {
-				locationStr = fmt.Sprintf("Lines %d-%d", start+1, end+1)
-			}
-		} else {
-			// Single line comment
-			var lineNum int
-			if _, err := fmt.Sscanf(location, "%d", &lineNum); err == nil {
-				locationStr = fmt.Sprintf("Line %d", lineNum+1)
-			}
-		}
+	m.setViewportContent(fd)
+	m.scrollToCursor()
+}
 
-		// Add comments for this location
-		builder.WriteString(fmt.Sprintf("### %s\n", locationStr))
-		for _, comment := range comments {
-			builder.WriteString(fmt.Sprintf("- %s\n", comment))
-		}
-		builder.WriteString("\n")
+// diffHeight returns the terminal lines left for the diff and the notes above it, once the
+// header, footer and the comment input while a comment is written have been laid out
+func (m *model) diffHeight() int {
+	height := m.height - verticalMarginHeight
+	if m.commentMode {
+		height -= lipgloss.Height(m.renderCommentInput())
+	}
+	return height
+}
+
+// fitViewport sizes the viewport to the terminal lines left over by the rest of the view,
+// keeping the cursor in view
+func (m *model) fitViewport() {
+	if !m.ready || m.height == 0 {
+		return
 	}
 
-	return builder.String()
+	height := m.diffHeight()
+	for _, note := range m.renderNotes() {
+		height -= lipgloss.Height(note)
+	}
+	height = max(height, 1)
+	if height != m.viewport.Height {
+		m.viewport.Height = height
+		m.scrollToCursor()
+	}
 }
 
-// saveCommentsToFile saves all comments to a file
-func (m *model) saveCommentsToFile() error {
-	content := m.exportComments()
-	if content == "No comments to export." {
-		return fmt.Errorf("no comments to save")
+// scrollToCursor scrolls the viewport so the cursor line is visible
+func (m *model) scrollToCursor() {
+	if m.cursorLine < m.viewport.YOffset || m.cursorLine >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(m.cursorLine - m.viewport.Height/2)
 	}
+}
 
-	filename := fmt.Sprintf("code-review-comments-%s.md", time.Now().Format("20060102-150405"))
+// parseDiff fetches and parses the diff for the file at the given index, caching the result.
+// Rendering is deferred to loadDiff so stats can be computed without highlighting every file.
+func (m *model) parseDiff(index int) (*fileDiff, error) {
+	file := m.changedFiles[index]
+	filename := file.Path
 
-	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to save file: %w", err)
+	// Check if already cached
+	if cached, exists := m.diffs[filename]; exists {
+		return cached, nil
 	}
 
-	m.statusMessage = fmt.Sprintf("💾 Saved to %s", filename)
-	return nil
-}
+	// Fetch diff from git
+	rawDiff, err := m.gitClient.GetFileDiff(m.mode, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load diff for %s: %w", filename, err)
+	}
 
-// copyCommentsToClipboard copies all comments to the clipboard
-func (m *model) copyCommentsToClipboard() error {
-	content := m.exportComments()
-	if content == "No comments to export." {
-		return fmt.Errorf("no comments to copy")
+	// Parse the diff so rendering and comment anchoring share the same rows
+	files, err := diff.Parse(rawDiff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse diff for %s: %w", filename, err)
 	}
 
-	if err := clipboard.WriteAll(content); err != nil {
-		return fmt.Errorf("failed to copy to clipboard: %w", err)
+	rows := diff.Rows(files)
+	fd := &fileDiff{
+		files:     files,
+		rows:      rows,
+		splitRows: diff.SplitRows(rows),
 	}
+	m.diffs[filename] = fd
 
-	m.statusMessage = "📋 Copied to clipboard"
-	return nil
+	// Comments may have been written against a different version of the diff
+	m.reanchorComments(filename, fd)
+	return fd, nil
 }
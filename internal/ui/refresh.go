@@ -0,0 +1,188 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/samverrall/review-ui/internal/diff"
+	"github.com/samverrall/review-ui/internal/git"
+)
+
+// fingerprintMsg reports the fingerprint of the reviewed changes, polled by watchFiles
+type fingerprintMsg struct {
+	fingerprint string
+	err         error
+}
+
+// watchFiles checks git for changes to the files under review once the watch interval has
+// passed. It returns nil if watching is disabled.
+func (m model) watchFiles() tea.Cmd {
+	if m.watchInterval <= 0 {
+		return nil
+	}
+	client, mode := m.gitClient, m.mode
+	return tea.Tick(m.watchInterval, func(time.Time) tea.Msg {
+		fingerprint, err := client.GetFingerprint(mode)
+		return fingerprintMsg{fingerprint: fingerprint, err: err}
+	})
+}
+
+// handleFingerprint reloads the changed files if the fingerprint shows they've changed on
+// disk, then schedules the next check
+func (m *model) handleFingerprint(msg fingerprintMsg) tea.Cmd {
+	if msg.err != nil {
+		// Git may be briefly unavailable, e.g. while the index is locked, so keep watching
+		m.logger.Debug("failed to check for changes", "error", msg.err)
+		return m.watchFiles()
+	}
+
+	if msg.fingerprint != m.fingerprint {
+		m.fingerprint = msg.fingerprint
+		if err := m.reloadChangedFiles(true); err != nil {
+			m.statusMessage = fmt.Sprintf("✗ Failed to reload changes: %v", err)
+		}
+	}
+	return m.watchFiles()
+}
+
+// reloadChangedFiles re-reads the changed files and discards the cached diff of the current
+// file, or of every file if all is set, staying on the current file, line and scroll position.
+// Added and removed files are announced in the status bar.
+func (m *model) reloadChangedFiles(all bool) error {
+	files, err := m.gitClient.GetChangedFiles(m.mode)
+	if err != nil {
+		return fmt.Errorf("failed to get changed files: %w", err)
+	}
+	sortChangedFiles(files)
+
+	added, removed := compareFileLists(m.changedFiles, files)
+	current := m.currentFile()
+	m.changedFiles = files
+	if all {
+		m.clearFileStats()
+		for name := range m.diffs {
+			if name != current {
+				delete(m.diffs, name)
+			}
+		}
+	}
+
+	if m.fileListCursor >= len(m.fileListEntries()) {
+		m.fileListCursor = max(len(m.fileListEntries())-1, 0)
+	}
+
+	m.statusMessage = refreshMessage(added, removed)
+	return m.reloadCurrentFile(current)
+}
+
+// reloadCurrentFile reloads the diff of the named file, keeping the cursor on the same file
+// line if it's still in the diff. If the file is no longer changed, the file now at its
+// position is shown instead.
+func (m *model) reloadCurrentFile(name string) error {
+	found := false
+	for i, file := range m.changedFiles {
+		if file.Path == name {
+			m.currentIndex, found = i, true
+			break
+		}
+	}
+	if !found {
+		delete(m.diffs, name)
+		m.currentIndex = min(m.currentIndex, max(len(m.changedFiles)-1, 0))
+		return m.loadDiff(m.currentIndex)
+	}
+
+	// Remember the code under the cursor, and at the start of an open selection, as the rows
+	// and line numbers may have shifted
+	line, side, anchor := m.codeAt(name, m.cursorLine)
+	selecting, selectionRow := m.selectionMode, m.selectionStart
+	selectionLine, selectionSide, selectionAnchor := m.codeAt(name, selectionRow)
+	row, offset := m.cursorLine, m.viewport.YOffset
+
+	// The cached diff is dropped so the file is read again
+	delete(m.diffs, name)
+	if err := m.loadDiff(m.currentIndex); err != nil {
+		return err
+	}
+
+	m.cursorLine = min(row, max(m.rowCount()-1, 0))
+	if start, _, ok := anchor.Locate(m.diffs[name].rows, side, line); ok {
+		line = start
+	}
+	m.moveCursorToLine(line, side)
+
+	// Keep the selection open, e.g. while an agent writes to the file being selected in
+	if selecting {
+		m.selectionMode = true
+		m.selectionStart = min(selectionRow, max(m.rowCount()-1, 0))
+		if start, _, ok := selectionAnchor.Locate(m.diffs[name].rows, selectionSide, selectionLine); ok {
+			selectionLine = start
+		}
+		if row, ok := m.rowOfLine(selectionLine, selectionSide); ok {
+			m.selectionStart = row
+		}
+	}
+
+	// Keep the cursor at the same height on screen
+	m.viewport.SetYOffset(offset + m.cursorLine - row)
+	m.scrollToCursor()
+	return nil
+}
+
+// codeAt returns the line number of a row of a file's diff, on the first side it's on, along
+// with the code around it so it can be found again after the diff changes. The line is 0 if
+// the row isn't a line of code.
+func (m *model) codeAt(name string, row int) (int, diff.Side, diff.Anchor) {
+	for _, side := range m.commentSides() {
+		if line := m.lineNumber(row, side); line != 0 {
+			var anchor diff.Anchor
+			if fd, exists := m.diffs[name]; exists {
+				anchor = diff.CaptureAnchor(fd.rows, side, line, line)
+			}
+			return line, side, anchor
+		}
+	}
+	return 0, m.cursorSide, diff.Anchor{}
+}
+
+// refresh reloads the changed files on request, reporting failures in the status bar
+func (m *model) refresh(all bool) {
+	if err := m.reloadChangedFiles(all); err != nil {
+		m.statusMessage = fmt.Sprintf("✗ Failed to refresh: %v", err)
+	}
+}
+
+// compareFileLists returns the paths that were added to and removed from a list of changed files
+func compareFileLists(before, after []git.ChangedFile) (added, removed []string) {
+	seen := make(map[string]bool, len(before))
+	for _, file := range before {
+		seen[file.Path] = true
+	}
+	for _, file := range after {
+		if !seen[file.Path] {
+			added = append(added, file.Path)
+		}
+		delete(seen, file.Path)
+	}
+	for _, file := range before {
+		if seen[file.Path] {
+			removed = append(removed, file.Path)
+		}
+	}
+	return added, removed
+}
+
+// refreshMessage describes a reload for the status bar, e.g. "⟳ Reloaded · added a.go · removed b.go"
+func refreshMessage(added, removed []string) string {
+	message := "⟳ Reloaded"
+	if len(added) > 0 {
+		message += " · added " + strings.Join(added, ", ")
+	}
+	if len(removed) > 0 {
+		message += " · removed " + strings.Join(removed, ", ")
+	}
+	return message
+}
@@ -0,0 +1,236 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samverrall/review-ui/internal/diff"
+	"github.com/samverrall/review-ui/internal/session"
+)
+
+// initSession opens the session store in the git directory and, if a review of this branch
+// was saved, holds it back until the user chooses whether to resume it.
+// Sessions are disabled when the repository details aren't available.
+func (m *model) initSession() {
+	info, err := m.gitClient.GetRepoInfo()
+	if err != nil || info.GitDir == "" {
+		m.logger.Debug("review sessions disabled", "error", err)
+		return
+	}
+
+	m.repo = info
+	m.sessions = session.NewStore(info.GitDir)
+
+	sess, err := m.sessions.Load(info.Branch, info.Head, m.mode)
+	if err != nil {
+		m.logger.Debug("failed to load session", "error", err)
+		m.statusMessage = fmt.Sprintf("✗ Failed to load saved review: %v", err)
+		return
+	}
+	if sess != nil && !sess.IsEmpty() {
+		m.pendingSession = sess
+	}
+}
+
+// saveSession saves the review state so it can be resumed after quitting. Nothing is saved
+// while the resume prompt is open, so a saved review is never overwritten before it's offered.
+func (m *model) saveSession() {
+	if m.sessions == nil || m.pendingSession != nil {
+		return
+	}
+
+	sess := &session.Session{
+		Branch:  m.repo.Branch,
+		Head:    m.repo.Head,
+		Mode:    m.mode,
+		File:    m.currentFile(),
+		SavedAt: time.Now(),
+	}
+
+	// Remember the cursor by line number, so it can be restored even if the diff changes
+	for _, side := range m.commentSides() {
+		if line := m.lineNumber(m.cursorLine, side); line != 0 {
+			sess.CursorLine, sess.CursorSide = line, side
+			break
+		}
+	}
+
+	for _, loc := range m.sortedCommentLocations() {
+		var anchor *diff.Anchor
+		if a, exists := m.anchors[loc]; exists {
+			anchor = &a
+		}
+		for _, c := range m.comments[loc] {
+			sess.Comments = append(sess.Comments, session.Comment{
+				ID:        c.ID,
+				File:      loc.File,
+				Side:      loc.Side,
+				StartLine: loc.StartLine,
+				EndLine:   loc.EndLine,
+				Body:      c.Body,
+				Severity:  c.Severity.String(),
+				ReplyTo:   c.ReplyTo,
+				Author:    c.Author,
+				CreatedAt: c.CreatedAt,
+				Resolved:  c.Resolved,
+				Anchor:    anchor,
+				Outdated:  m.outdated[loc],
+			})
+		}
+	}
+	for file, reviewed := range m.reviewed {
+		if reviewed {
+			sess.Reviewed = append(sess.Reviewed, file)
+		}
+	}
+	sort.Strings(sess.Reviewed)
+
+	if err := m.sessions.Save(sess); err != nil {
+		m.logger.Debug("failed to save session", "error", err)
+		m.statusMessage = fmt.Sprintf("✗ Failed to save review: %v", err)
+	}
+}
+
+// resumeSession restores a saved review of the same changes: its comments, reviewed files
+// and cursor. Only sessions saved in the mode given on the command line are offered.
+func (m *model) resumeSession(sess *session.Session) error {
+	for _, c := range sess.Comments {
+		loc := commentLocation{File: c.File, Side: c.Side, StartLine: c.StartLine, EndLine: c.EndLine}
+		// Sessions saved before comments had IDs are given new ones
+		id := c.ID
+		if id <= 0 {
+			id = m.nextCommentID
+		}
+		// Tags this version doesn't know are dropped rather than losing the comment
+		sev, err := parseSeverity(c.Severity)
+		if err != nil {
+			m.logger.Debug("ignoring comment severity", "error", err)
+		}
+		m.comments[loc] = append(m.comments[loc], comment{
+			ID:        id,
+			Body:      c.Body,
+			Severity:  sev,
+			ReplyTo:   c.ReplyTo,
+			Author:    c.Author,
+			CreatedAt: c.CreatedAt,
+			Resolved:  c.Resolved,
+		})
+		m.nextCommentID = max(m.nextCommentID, id) + 1
+		if c.Anchor != nil {
+			m.anchors[loc] = *c.Anchor
+		}
+		if c.Outdated {
+			m.outdated[loc] = true
+		}
+	}
+
+	// The saved line numbers may not match the current diff, so relocate the comments
+	// on every diff that's already loaded; the rest are relocated as they're loaded
+	for file, fd := range m.diffs {
+		m.reanchorComments(file, fd)
+	}
+	for _, file := range sess.Reviewed {
+		m.reviewed[file] = true
+	}
+
+	// Return to where the review was left off
+	for i, file := range m.changedFiles {
+		if file.Path != sess.File {
+			continue
+		}
+		m.currentIndex = i
+		if err := m.loadDiff(i); err != nil {
+			return err
+		}
+		m.moveCursorToLine(sess.CursorLine, sess.CursorSide)
+		break
+	}
+
+	m.statusMessage = fmt.Sprintf("↺ Resumed review with %d comments", len(sess.Comments))
+	return nil
+}
+
+// toggleReviewed marks or unmarks the current file as reviewed
+func (m *model) toggleReviewed() {
+	file := m.currentFile()
+	if file == "" {
+		return
+	}
+
+	m.execute(&reviewedCommand{file: file, reviewed: !m.reviewed[file]})
+	if m.reviewed[file] {
+		m.statusMessage = fmt.Sprintf("✓ Marked %s as reviewed", file)
+	} else {
+		m.statusMessage = fmt.Sprintf("○ Unmarked %s", file)
+	}
+}
+
+// setReviewed marks or unmarks a file as reviewed without recording it in the history
+func (m *model) setReviewed(file string, reviewed bool) {
+	if reviewed {
+		m.reviewed[file] = true
+	} else {
+		delete(m.reviewed, file)
+	}
+}
+
+// reviewedCount returns the number of changed files marked as reviewed
+func (m *model) reviewedCount() int {
+	count := 0
+	for _, file := range m.changedFiles {
+		if m.reviewed[file.Path] {
+			count++
+		}
+	}
+	return count
+}
+
+// renderResumePrompt renders the prompt offering to resume a saved review
+func (m model) renderResumePrompt() string {
+	sess := m.pendingSession
+
+	var b strings.Builder
+	b.WriteString(headerStyle.Render("↺ Resume previous review?"))
+	b.WriteString("\n\n")
+
+	details := []string{
+		fmt.Sprintf("Reviewing %s", sess.Mode),
+		fmt.Sprintf("Saved %s", sess.SavedAt.Local().Format("2006-01-02 15:04")),
+		fmt.Sprintf("%d comments · %d files reviewed", len(sess.Comments), len(sess.Reviewed)),
+	}
+	if sess.Head != m.repo.Head {
+		details = append(details, "HEAD has moved since, comments will be matched to the current code")
+	}
+	b.WriteString(fileListItemStyle.Render(strings.Join(details, "\n")))
+	b.WriteString("\n\n")
+
+	b.WriteString(footerStyle.Render("y resume | n start fresh | q quit"))
+	return modalContainer.Render(b.String())
+}
+
+// moveCursorToLine moves the cursor to the row showing a line of the current file,
+// leaving it where it is if the line isn't in the diff
+func (m *model) moveCursorToLine(line int, side diff.Side) {
+	if row, ok := m.rowOfLine(line, side); ok {
+		m.cursorLine = row
+		if m.splitView {
+			m.cursorSide = side
+		}
+		m.scrollToCursor()
+	}
+}
+
+// rowOfLine returns the row of the current diff showing a line of one side of the file
+func (m *model) rowOfLine(line int, side diff.Side) (int, bool) {
+	if line == 0 {
+		return 0, false
+	}
+	for row := 0; row < m.rowCount(); row++ {
+		if m.lineNumber(row, side) == line {
+			return row, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/samverrall/review-ui/internal/ui/color"
+)
+
+// severity categorises a comment so the most important feedback can be addressed first
+type severity int
+
+const (
+	severityNone       severity = iota // Untagged comment
+	severityBlocking                   // Must be fixed before the change is accepted
+	severitySuggestion                 // Worth changing, but not required
+	severityNit                        // Minor style or naming issue
+	severityQuestion                   // Needs an answer rather than a change
+	severityPraise                     // Something done well
+)
+
+// severities lists the tags in the order they're picked and exported
+var severities = []severity{severityBlocking, severitySuggestion, severityNit, severityQuestion, severityPraise}
+
+var severityNames = map[severity]string{
+	severityBlocking:   "blocking",
+	severitySuggestion: "suggestion",
+	severityNit:        "nit",
+	severityQuestion:   "question",
+	severityPraise:     "praise",
+}
+
+var severityColors = map[severity]lipgloss.Color{
+	severityBlocking:   color.MoonRed,
+	severitySuggestion: color.MoonBlue,
+	severityNit:        color.MoonGray,
+	severityQuestion:   color.MoonYellow,
+	severityPraise:     color.MoonGreen,
+}
+
+// String returns the tag of the severity, e.g. "nit", or "" for untagged comments
+func (s severity) String() string {
+	return severityNames[s]
+}
+
+// badge renders the severity as a colored label, or "" for untagged comments
+func (s severity) badge() string {
+	if s == severityNone {
+		return ""
+	}
+	return lipgloss.NewStyle().
+		Foreground(color.DarkBg).
+		Background(severityColors[s]).
+		Bold(true).
+		Padding(0, 1).
+		Render(strings.ToUpper(s.String()))
+}
+
+// next returns the severity after s when cycling through the picker, wrapping to untagged
+func (s severity) next(delta int) severity {
+	count := len(severities) + 1
+	return severity(((int(s)+delta)%count + count) % count)
+}
+
+// parseSeverity parses a severity tag, e.g. "nit". An empty tag is untagged.
+func parseSeverity(name string) (severity, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return severityNone, nil
+	}
+	for s, n := range severityNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return severityNone, fmt.Errorf("unknown severity %q, expected one of blocking, suggestion, nit, question or praise", name)
+}
+
+// parseSeverityPrefix splits a severity prefix off a comment, e.g. "nit: rename this",
+// returning the comment unchanged if it doesn't start with one
+func parseSeverityPrefix(body string) (severity, string) {
+	tag, rest, found := strings.Cut(body, ":")
+	if !found || strings.ContainsAny(tag, " \t\n") {
+		return severityNone, body
+	}
+	s, err := parseSeverity(tag)
+	if err != nil || s == severityNone {
+		return severityNone, body
+	}
+	return s, strings.TrimLeft(rest, " \t")
+}
+
+// renderSeverityPicker renders the severities to choose from while writing a comment,
+// with the chosen one as a badge
+func renderSeverityPicker(selected severity) string {
+	options := []string{"Severity (tab):"}
+	for _, s := range append([]severity{severityNone}, severities...) {
+		switch {
+		case s == selected && s == severityNone:
+			options = append(options, lipgloss.NewStyle().Bold(true).Render("none"))
+		case s == selected:
+			options = append(options, s.badge())
+		case s == severityNone:
+			options = append(options, lipgloss.NewStyle().Foreground(color.SubtleText).Render("none"))
+		default:
+			options = append(options, lipgloss.NewStyle().Foreground(color.SubtleText).Render(s.String()))
+		}
+	}
+	return strings.Join(options, " ")
+}
@@ -51,6 +51,11 @@ var (
 			Background(color.CursorLineBg).
 			Foreground(color.TextColor)
 
+	// Separator arrow pointing at the cursor column in the side-by-side view
+	splitCursorStyle = lipgloss.NewStyle().
+				Foreground(color.MoonBlue).
+				Bold(true)
+
 	// Selection style for highlighting selected lines
 	selectionStyle = lipgloss.NewStyle().
 			Background(color.MoonPurple).
@@ -66,6 +71,27 @@ var (
 			Padding(0, 1).
 			Margin(0, 2)
 
+	// Comments whose code is no longer in the diff
+	outdatedCommentStyle = commentStyle.
+				Foreground(color.MoonYellow).
+				BorderForeground(color.MoonYellow)
+
+	// Comments in resolved threads
+	resolvedCommentStyle = commentStyle.
+				Foreground(color.SubtleText).
+				BorderForeground(color.MoonDarkGray)
+
+	// Comment that e and d act on
+	focusedCommentStyle = commentStyle.
+				Foreground(color.TextColor).
+				BorderForeground(color.MoonGreen).
+				Bold(true)
+
+	// Count of notes left out under the file header for lack of room
+	hiddenNotesStyle = lipgloss.NewStyle().
+				Foreground(color.SubtleText).
+				Margin(0, 2)
+
 	// Comment input style for the input box
 	commentInputStyle = lipgloss.NewStyle().
 				Border(lipgloss.RoundedBorder()).
@@ -74,7 +100,7 @@ var (
 				Background(color.DarkBg).
 				Padding(1, 2).
 				Margin(1, 0).
-				Width(80)
+				Width(commentInputWidth + 4)
 
 	// Status style for success/error messages
 	statusStyle = lipgloss.NewStyle().
@@ -95,6 +121,42 @@ var (
 				Bold(true).
 				Padding(0, 1)
 
+	// File status styles for the file list
+	statusAddedStyle    = lipgloss.NewStyle().Foreground(color.MoonGreen)
+	statusDeletedStyle  = lipgloss.NewStyle().Foreground(color.MoonRed)
+	statusModifiedStyle = lipgloss.NewStyle().Foreground(color.MoonYellow)
+	statusRenamedStyle  = lipgloss.NewStyle().Foreground(color.MoonBlue)
+	statusConflictStyle = lipgloss.NewStyle().Foreground(color.MoonRed).Bold(true)
+
+	// Segments of the selected file list row, which share its background
+	fileListSelectedSegmentStyle = lipgloss.NewStyle().
+					Foreground(color.DarkBg).
+					Background(color.MoonBlue).
+					Bold(true)
+
+	// Fuzzy filter matches in the file list
+	fileListMatchStyle = lipgloss.NewStyle().
+				Foreground(color.MoonYellow).
+				Bold(true)
+
+	fileListSelectedMatchStyle = lipgloss.NewStyle().
+					Foreground(color.MoonYellow).
+					Background(color.MoonBlue).
+					Bold(true).
+					Underline(true)
+
+	// Directory rows in the file list tree
+	fileListDirStyle = lipgloss.NewStyle().Foreground(color.SubtleText)
+
+	// Diffstat styles for added and deleted line counts
+	diffStatAddedStyle   = lipgloss.NewStyle().Foreground(color.MoonGreen)
+	diffStatDeletedStyle = lipgloss.NewStyle().Foreground(color.MoonRed)
+
+	// File list summary line
+	fileListSummaryStyle = lipgloss.NewStyle().
+				Foreground(color.SubtleText).
+				Padding(0, 1)
+
 	// Modal container for centered content
 	modalContainer = lipgloss.NewStyle().
 			Padding(1, 4).
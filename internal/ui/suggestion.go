@@ -0,0 +1,189 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/samverrall/review-ui/internal/diff"
+)
+
+// suggestionFence opens the block of replacement code in a suggestion comment, as in
+// GitHub's suggested changes. The block replaces every line the comment covers.
+const suggestionFence = "```suggestion"
+
+// suggestedLines returns the replacement code of a suggestion comment. An empty block
+// suggests deleting the lines. It returns false if the comment isn't a suggestion.
+func suggestedLines(body string) ([]string, bool) {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != suggestionFence {
+			continue
+		}
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == "```" {
+				return lines[i+1 : j], true
+			}
+		}
+		// An unclosed block runs to the end of the comment, as in markdown
+		return lines[i+1:], true
+	}
+	return nil, false
+}
+
+// suggestionTemplate returns the body a new suggestion starts with: the commented lines,
+// ready to be edited into the replacement
+func (m *model) suggestionTemplate(target commentLocation) string {
+	var b strings.Builder
+	b.WriteString(suggestionFence + "\n")
+	if fd, exists := m.diffs[target.File]; exists {
+		for _, row := range fd.rows {
+			if n := row.Number(target.Side); row.Line != nil && n >= target.StartLine && n <= target.EndLine {
+				b.WriteString(row.Line.Content + "\n")
+			}
+		}
+	}
+	b.WriteString("```")
+	return b.String()
+}
+
+// restoreSuggestionTabs undoes the comment editor's expansion of tabs to four spaces in the
+// code of a suggestion, if the commented code is indented with tabs
+func (m *model) restoreSuggestionTabs(target commentLocation, body string) string {
+	fd, exists := m.diffs[target.File]
+	if !exists {
+		return body
+	}
+	tabs := false
+	for _, row := range fd.rows {
+		if n := row.Number(target.Side); row.Line != nil && n >= target.StartLine && n <= target.EndLine {
+			tabs = tabs || strings.HasPrefix(row.Line.Content, "\t")
+		}
+	}
+	if !tabs {
+		return body
+	}
+
+	lines := strings.Split(body, "\n")
+	inBlock := false
+	for i, line := range lines {
+		switch {
+		case strings.TrimSpace(line) == suggestionFence:
+			inBlock = true
+		case inBlock && strings.TrimSpace(line) == "```":
+			inBlock = false
+		case inBlock:
+			indent := len(line) - len(strings.TrimLeft(line, " "))
+			lines[i] = strings.Repeat("\t", indent/4) + line[indent/4*4:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// applySuggestion replaces the lines of the working tree file that a suggestion comment
+// covers with its code. The file must still contain the code the suggestion was written
+// against: at the commented lines, or failing that exactly once elsewhere in the file.
+func (m *model) applySuggestion(id int) error {
+	c, loc, ok := m.findComment(id)
+	if !ok {
+		return fmt.Errorf("comment not found")
+	}
+	replacement, ok := suggestedLines(c.Body)
+	if !ok {
+		return fmt.Errorf("comment isn't a suggestion")
+	}
+	if loc.Side != diff.SideNew {
+		return fmt.Errorf("suggestions can only be applied to the new version of a file")
+	}
+	anchor, exists := m.anchors[loc]
+	if !exists || anchor.IsEmpty() {
+		return fmt.Errorf("the code the suggestion was written against is unknown")
+	}
+
+	content, err := os.ReadFile(loc.File)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", loc.File, err)
+	}
+	updated, err := replaceLines(string(content), loc.StartLine, anchor.Lines, replacement)
+	if err != nil {
+		return fmt.Errorf("%s: %w", loc.File, err)
+	}
+	info, err := os.Stat(loc.File)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", loc.File, err)
+	}
+	if err := os.WriteFile(loc.File, []byte(updated), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", loc.File, err)
+	}
+
+	// The suggestion now describes the applied code, so it follows that code when reloaded
+	if len(replacement) > 0 {
+		anchor.Lines = replacement
+		m.anchors[loc] = anchor
+	}
+	return nil
+}
+
+// replaceLines replaces the original lines of content, expected at line start, with the
+// replacement lines. Line endings and a missing final newline are preserved.
+func replaceLines(content string, start int, original, replacement []string) (string, error) {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	matches := func(i int) bool {
+		if i < 0 || i+len(original) > len(lines) {
+			return false
+		}
+		for k, want := range original {
+			if strings.TrimRight(lines[i+k], "\r\n") != strings.TrimRight(want, "\r") {
+				return false
+			}
+		}
+		return true
+	}
+
+	at := start - 1
+	if !matches(at) {
+		at = -1
+		for i := range lines {
+			if !matches(i) {
+				continue
+			}
+			if at >= 0 {
+				return "", fmt.Errorf("the suggested lines appear more than once since the file changed")
+			}
+			at = i
+		}
+		if at < 0 {
+			return "", fmt.Errorf("the suggested lines have changed, the suggestion no longer applies")
+		}
+	}
+
+	// Use the line ending of the replaced lines, and keep the end of the file unterminated
+	// if it was
+	last := lines[at+len(original)-1]
+	eol := "\n"
+	if strings.HasSuffix(last, "\r\n") {
+		eol = "\r\n"
+	}
+	var replaced []string
+	for _, line := range replacement {
+		replaced = append(replaced, line+eol)
+	}
+	if len(replaced) > 0 && !strings.HasSuffix(last, "\n") {
+		replaced[len(replaced)-1] = replacement[len(replacement)-1]
+	}
+
+	var b strings.Builder
+	for _, line := range lines[:at] {
+		b.WriteString(line)
+	}
+	for _, line := range replaced {
+		b.WriteString(line)
+	}
+	for _, line := range lines[at+len(original):] {
+		b.WriteString(line)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,159 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// thread is a root comment and the replies to it. Threads are stored flattened in
+// model.comments, with each thread's replies following its root.
+type thread struct {
+	root    comment
+	replies []comment
+}
+
+// resolved reports whether the thread has been marked as resolved
+func (t thread) resolved() bool {
+	return t.root.Resolved
+}
+
+// comments returns the root and replies in display order
+func (t thread) comments() []comment {
+	return append([]comment{t.root}, t.replies...)
+}
+
+// commentThreads groups the comments at a location into threads, in the order they were started
+func commentThreads(comments []comment) []thread {
+	var threads []thread
+	index := make(map[int]int)
+	for _, c := range comments {
+		if i, exists := index[c.ReplyTo]; exists && c.ReplyTo != 0 {
+			threads[i].replies = append(threads[i].replies, c)
+			continue
+		}
+		// Replies whose root is missing are shown as threads of their own
+		index[c.ID] = len(threads)
+		threads = append(threads, thread{root: c})
+	}
+	return threads
+}
+
+// visibleThreads returns the threads at a location that are shown, leaving out resolved
+// threads while they're hidden
+func (m *model) visibleThreads(loc commentLocation) []thread {
+	var threads []thread
+	for _, t := range commentThreads(m.comments[loc]) {
+		if !m.hideResolved || !t.resolved() {
+			threads = append(threads, t)
+		}
+	}
+	return threads
+}
+
+// threadOf returns the root comment of the thread a comment belongs to
+func (m *model) threadOf(id int) (comment, bool) {
+	c, _, ok := m.findComment(id)
+	if ok && c.ReplyTo != 0 {
+		if root, _, found := m.findComment(c.ReplyTo); found {
+			return root, true
+		}
+	}
+	return c, ok
+}
+
+// addReply adds a reply to the end of a thread
+func (m *model) addReply(rootID int, body string, sev severity) bool {
+	_, loc, ok := m.findComment(rootID)
+	if !ok {
+		return false
+	}
+	index := 0
+	for i, c := range m.comments[loc] {
+		if c.ID == rootID || c.ReplyTo == rootID {
+			index = i + 1
+		}
+	}
+
+	c := m.newComment(body, sev)
+	c.ReplyTo = rootID
+	m.execute(&addCommentCommand{saved: savedComment{loc: loc, comment: c, index: index}})
+	return true
+}
+
+// toggleResolved marks the thread a comment belongs to as resolved, or open again
+func (m *model) toggleResolved(id int) {
+	root, ok := m.threadOf(id)
+	if !ok {
+		return
+	}
+	m.execute(&resolveCommand{id: root.ID, resolved: !root.Resolved})
+	if root.Resolved {
+		m.statusMessage = "○ Reopened thread"
+	} else {
+		m.statusMessage = "✓ Resolved thread"
+	}
+}
+
+// setResolved sets the resolved flag of a thread's root without recording it in the history
+func (m *model) setResolved(id int, resolved bool) {
+	if c, _, ok := m.findComment(id); ok {
+		c.Resolved = resolved
+		m.replaceComment(c)
+	}
+}
+
+// resolvedThreadCount returns the number of resolved threads on a file
+func (m *model) resolvedThreadCount(file string) int {
+	count := 0
+	for loc, comments := range m.comments {
+		if loc.File != file {
+			continue
+		}
+		for _, t := range commentThreads(comments) {
+			if t.resolved() {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// newComment creates a comment by the reviewer with the next ID
+func (m *model) newComment(body string, sev severity) comment {
+	c := comment{ID: m.nextCommentID, Body: body, Severity: sev, Author: m.author(), CreatedAt: time.Now()}
+	m.nextCommentID++
+	return c
+}
+
+// author returns the name comments are written under: the git user, or the login name
+func (m *model) author() string {
+	if m.repo.User != "" {
+		return m.repo.User
+	}
+	return os.Getenv("USER")
+}
+
+// resolveCommand marks a thread as resolved or reopens it
+type resolveCommand struct {
+	id       int
+	resolved bool
+}
+
+func (c *resolveCommand) do(m *model)   { m.setResolved(c.id, c.resolved) }
+func (c *resolveCommand) undo(m *model) { m.setResolved(c.id, !c.resolved) }
+
+func (c *resolveCommand) describe() string {
+	if c.resolved {
+		return "resolve thread"
+	}
+	return "reopen thread"
+}
+
+// replyLabel describes a reply for display, e.g. "↳ alice: Done"
+func replyLabel(c comment) string {
+	if c.Author == "" {
+		return fmt.Sprintf("↳ %s", c.Body)
+	}
+	return fmt.Sprintf("↳ %s: %s", c.Author, c.Body)
+}
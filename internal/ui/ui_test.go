@@ -1,42 +1,115 @@
 package ui
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 
+	"github.com/samverrall/review-ui/internal/diff"
 	"github.com/samverrall/review-ui/internal/git"
 	"github.com/samverrall/review-ui/internal/git/testutil"
 )
 
+// sampleDiff is a small diff whose rows map to real file lines as follows:
+//
+//	row 0-3: headers, row 4: hunk header
+//	row 5: context  old 10 / new 10
+//	row 6: deleted  old 11
+//	row 7: added    new 11
+//	row 8: added    new 12
+//	row 9: context  old 12 / new 13
+const sampleDiff = `diff --git a/file1.go b/file1.go
+index 1111111..2222222 100644
+--- a/file1.go
++++ b/file1.go
+@@ -10,3 +10,4 @@ func main() {
+ 	a := 1
+-	b := 2
++	b := 3
++	c := 4
+ 	return
+`
 
 // Helper function to create a test model with mocked dependencies
 func createTestModel(mock git.GitClient) model {
-	ti := textinput.New()
-	ti.Placeholder = "Enter your comment..."
-	ti.CharLimit = 200
-	ti.Width = 80
-
 	// Initialize viewport with basic dimensions
 	vp := viewport.New(80, 20)
 
 	// Get changed files from mock for testing
-	var changedFiles []string
+	var changedFiles []git.ChangedFile
 	if mockClient, ok := mock.(*testutil.MockGitClient); ok {
 		changedFiles = mockClient.GetChangedFilesForTest()
 	}
 
 	return model{
-		gitClient:    mock,
-		changedFiles: changedFiles,
-		currentIndex: 0,
-		diffs:        make(map[string]string),
-		viewport:     vp,
-		commentInput: ti,
-		commentMode:  false,
-		comments:     make(map[string][]string),
+		gitClient:     mock,
+		changedFiles:  changedFiles,
+		currentIndex:  0,
+		diffs:         make(map[string]*fileDiff),
+		viewport:      vp,
+		commentInput:  newCommentInput(),
+		commentMode:   false,
+		comments:      make(map[commentLocation][]comment),
+		nextCommentID: 1,
+		reviewed:      make(map[string]bool),
+		anchors:       make(map[commentLocation]diff.Anchor),
+		outdated:      make(map[commentLocation]bool),
+		collapsedDirs: make(map[string]bool),
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// createTestModelWithDiff creates a test model for file1.go with sampleDiff loaded
+func createTestModelWithDiff(t *testing.T) model {
+	t.Helper()
+	mock := testutil.NewMockGitClient().
+		WithIsRepo(true).
+		WithChangedFiles([]string{"file1.go", "file2.go"}).
+		WithFileDiff("file1.go", sampleDiff)
+
+	m := createTestModel(mock)
+	if err := m.loadDiff(0); err != nil {
+		t.Fatalf("failed to load diff: %v", err)
+	}
+	return m
+}
+
+// keyTypes are the special keys sendKeys accepts by name
+var keyTypes = map[string]tea.KeyType{
+	"enter":     tea.KeyEnter,
+	"esc":       tea.KeyEsc,
+	"tab":       tea.KeyTab,
+	"shift+tab": tea.KeyShiftTab,
+	"ctrl+d":    tea.KeyCtrlD,
+	"ctrl+r":    tea.KeyCtrlR,
+	"ctrl+s":    tea.KeyCtrlS,
+	"ctrl+u":    tea.KeyCtrlU,
+}
+
+// sendKeys sends keys to the model in order. Special keys are named as bubbletea names them,
+// e.g. "ctrl+s" or "alt+enter", anything else is typed as text.
+func sendKeys(t *testing.T, m *model, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		name, alt := strings.CutPrefix(key, "alt+")
+		if keyType, ok := keyTypes[name]; ok {
+			msg = tea.KeyMsg{Type: keyType, Alt: alt}
+		}
+		updatedModel, _ := m.Update(msg)
+		*m = updatedModel.(model)
 	}
 }
 
@@ -201,11 +274,7 @@ func TestSelectionMode(t *testing.T) {
 }
 
 func TestCommentFunctionality(t *testing.T) {
-	mock := testutil.NewMockGitClient().
-		WithIsRepo(true).
-		WithChangedFiles([]string{"file1.go"})
-
-	m := createTestModel(mock)
+	m := createTestModelWithDiff(t)
 
 	// Test initial state
 	if m.commentMode {
@@ -215,35 +284,61 @@ func TestCommentFunctionality(t *testing.T) {
 		t.Errorf("expected no initial comments, got %d", len(m.comments))
 	}
 
-	// Enter comment mode
+	// Commenting on a header row is rejected
 	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
 	m = updatedModel.(model)
+	if m.commentMode {
+		t.Errorf("expected commentMode false on header row, got %v", m.commentMode)
+	}
+
+	// Enter comment mode on the added "b := 3" row
+	m.cursorLine = 7
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	m = updatedModel.(model)
 	if !m.commentMode {
 		t.Errorf("expected commentMode true after 'c', got %v", m.commentMode)
 	}
+	expectedTarget := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 11}
+	if m.commentTarget != expectedTarget {
+		t.Errorf("expected comment target %+v, got %+v", expectedTarget, m.commentTarget)
+	}
 
-	// Test adding a comment (simplified - normally this would be done through text input)
-	// This is hard to test fully without mocking the text input behavior
-	// In practice, you'd test the comment storage logic separately
-
-	// Test comment key generation
-	key := m.getCommentKey(5)
-	expectedKey := "file1.go:5"
-	if key != expectedKey {
-		t.Errorf("expected comment key %s, got %s", expectedKey, key)
+	// Type and save a comment
+	m.commentInput.SetValue("Use a constant")
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	m = updatedModel.(model)
+	if got := m.comments[expectedTarget]; len(got) != 1 || got[0].Body != "Use a constant" {
+		t.Errorf("expected comment stored at %+v, got %v", expectedTarget, got)
 	}
+}
+
+func TestCommentKeyLineMapping(t *testing.T) {
+	m := createTestModelWithDiff(t)
 
-	// Test range comment key generation
-	rangeKey := m.getCommentKeyForRange(5, 10)
-	expectedRangeKey := "file1.go:5-10"
-	if rangeKey != expectedRangeKey {
-		t.Errorf("expected range comment key %s, got %s", expectedRangeKey, rangeKey)
+	tests := []struct {
+		name     string
+		row      int
+		expected commentLocation
+		ok       bool
+	}{
+		{name: "header row", row: 0, ok: false},
+		{name: "hunk header row", row: 4, ok: false},
+		{name: "context row", row: 5, expected: commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 10, EndLine: 10}, ok: true},
+		{name: "deleted row", row: 6, expected: commentLocation{File: "file1.go", Side: diff.SideOld, StartLine: 11, EndLine: 11}, ok: true},
+		{name: "added row", row: 8, expected: commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 12, EndLine: 12}, ok: true},
+		{name: "context row after changes", row: 9, expected: commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 13, EndLine: 13}, ok: true},
 	}
 
-	// Test range comment key generation with swapped order
-	rangeKey2 := m.getCommentKeyForRange(10, 5)
-	if rangeKey2 != expectedRangeKey {
-		t.Errorf("expected range comment key %s even with swapped args, got %s", expectedRangeKey, rangeKey2)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, ok := m.getCommentKey(tt.row)
+			if ok != tt.ok {
+				t.Fatalf("expected ok %v, got %v", tt.ok, ok)
+			}
+			if ok && loc != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, loc)
+			}
+		})
 	}
 }
 
@@ -255,19 +350,19 @@ func TestExportFunctionality(t *testing.T) {
 	m := createTestModel(mock)
 
 	// Test export with no comments
-	exported := m.exportComments()
+	exported := exportText(t, &m)
 	expected := "No comments to export."
 	if exported != expected {
 		t.Errorf("expected '%s', got '%s'", expected, exported)
 	}
 
 	// Add some test comments
-	m.comments["file1.go:5"] = []string{"This line needs improvement"}
-	m.comments["file1.go:10-15"] = []string{"This block could be refactored"}
-	m.comments["file2.go:20"] = []string{"Consider error handling"}
+	m.comments[commentLocation{File: "file1.go", StartLine: 5, EndLine: 5}] = []comment{{ID: 1, Body: "This line needs improvement"}}
+	m.comments[commentLocation{File: "file1.go", StartLine: 10, EndLine: 15}] = []comment{{ID: 2, Body: "This block could be refactored"}}
+	m.comments[commentLocation{File: "file2.go", StartLine: 20, EndLine: 20}] = []comment{{ID: 3, Body: "Consider error handling"}}
 
 	// Test export with comments
-	exported = m.exportComments()
+	exported = exportText(t, &m)
 	if exported == "No comments to export." {
 		t.Errorf("expected comments to be exported, but got no comments message")
 	}
@@ -288,11 +383,7 @@ func TestExportFunctionality(t *testing.T) {
 }
 
 func TestCommentRangeHandling(t *testing.T) {
-	mock := testutil.NewMockGitClient().
-		WithIsRepo(true).
-		WithChangedFiles([]string{"file1.go", "file2.go"})
-
-	m := createTestModel(mock)
+	m := createTestModelWithDiff(t)
 
 	// Test getSelectionRange with normal order
 	m.selectionStart = 5
@@ -310,17 +401,29 @@ func TestCommentRangeHandling(t *testing.T) {
 		t.Errorf("expected range 5-10 (normalized), got %d-%d", start, end)
 	}
 
-	// Test range comment key generation
-	key := m.getCommentKeyForRange(5, 10)
-	expectedKey := "file1.go:5-10"
-	if key != expectedKey {
-		t.Errorf("expected key %s, got %s", expectedKey, key)
+	// A range spanning new-side lines anchors to the new file lines it covers
+	key, ok := m.getCommentKeyForRange(5, 8)
+	expectedKey := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 10, EndLine: 12}
+	if !ok || key != expectedKey {
+		t.Errorf("expected key %+v, got %+v", expectedKey, key)
 	}
 
 	// Test range comment key generation with swapped args (should normalize)
-	key2 := m.getCommentKeyForRange(10, 5)
+	key2, _ := m.getCommentKeyForRange(8, 5)
 	if key2 != expectedKey {
-		t.Errorf("expected key %s even with swapped args, got %s", expectedKey, key2)
+		t.Errorf("expected key %+v even with swapped args, got %+v", expectedKey, key2)
+	}
+
+	// A range of only deleted lines anchors to the old file
+	key3, ok := m.getCommentKeyForRange(6, 6)
+	expectedOld := commentLocation{File: "file1.go", Side: diff.SideOld, StartLine: 11, EndLine: 11}
+	if !ok || key3 != expectedOld {
+		t.Errorf("expected key %+v, got %+v", expectedOld, key3)
+	}
+
+	// A range of only header rows can't be commented on
+	if _, ok := m.getCommentKeyForRange(0, 4); ok {
+		t.Errorf("expected header-only range to be rejected")
 	}
 }
 
@@ -331,22 +434,25 @@ func TestCommentStorageAndRetrieval(t *testing.T) {
 
 	m := createTestModel(mock)
 
+	line5 := commentLocation{File: "file1.go", StartLine: 5, EndLine: 5}
+	line10 := commentLocation{File: "file2.go", StartLine: 10, EndLine: 10}
+
 	// Test adding single line comments
-	m.comments["file1.go:5"] = []string{"First comment"}
-	m.comments["file1.go:5"] = append(m.comments["file1.go:5"], "Second comment")
-	m.comments["file2.go:10"] = []string{"Comment on different file"}
+	m.comments[line5] = []comment{{ID: 1, Body: "First comment"}}
+	m.comments[line5] = append(m.comments[line5], comment{ID: 2, Body: "Second comment"})
+	m.comments[line10] = []comment{{ID: 3, Body: "Comment on different file"}}
 
 	// Test retrieving comments
-	if len(m.comments["file1.go:5"]) != 2 {
-		t.Errorf("expected 2 comments for line 5, got %d", len(m.comments["file1.go:5"]))
+	if len(m.comments[line5]) != 2 {
+		t.Errorf("expected 2 comments for line 5, got %d", len(m.comments[line5]))
 	}
 
-	if m.comments["file1.go:5"][0] != "First comment" {
-		t.Errorf("expected first comment 'First comment', got '%s'", m.comments["file1.go:5"][0])
+	if m.comments[line5][0].Body != "First comment" {
+		t.Errorf("expected first comment 'First comment', got '%s'", m.comments[line5][0].Body)
 	}
 
-	if len(m.comments["file2.go:10"]) != 1 {
-		t.Errorf("expected 1 comment for file2 line 10, got %d", len(m.comments["file2.go:10"]))
+	if len(m.comments[line10]) != 1 {
+		t.Errorf("expected 1 comment for file2 line 10, got %d", len(m.comments[line10]))
 	}
 }
 
@@ -358,12 +464,12 @@ func TestExportWithMultipleFilesAndComments(t *testing.T) {
 	m := createTestModel(mock)
 
 	// Add comments to multiple files and ranges
-	m.comments["file1.go:5"] = []string{"Single line comment"}
-	m.comments["file1.go:10-15"] = []string{"Range comment on file1"}
-	m.comments["file2.go:20"] = []string{"Comment on file2 line 20"}
-	m.comments["file2.go:25"] = []string{"Another comment on file2"}
+	m.comments[commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 6, EndLine: 6}] = []comment{{ID: 1, Body: "Single line comment"}}
+	m.comments[commentLocation{File: "file1.go", Side: diff.SideOld, StartLine: 11, EndLine: 16}] = []comment{{ID: 2, Body: "Range comment on file1"}}
+	m.comments[commentLocation{File: "file2.go", Side: diff.SideNew, StartLine: 20, EndLine: 20}] = []comment{{ID: 3, Body: "Comment on file2 line 20"}}
+	m.comments[commentLocation{File: "file2.go", Side: diff.SideNew, StartLine: 25, EndLine: 25}] = []comment{{ID: 4, Body: "Another comment on file2"}}
 
-	exported := m.exportComments()
+	exported := exportText(t, &m)
 
 	// Check that export contains expected elements
 	if !contains(exported, "# Code Review Comments") {
@@ -378,12 +484,12 @@ func TestExportWithMultipleFilesAndComments(t *testing.T) {
 		t.Errorf("expected file2.go section")
 	}
 
-	if !contains(exported, "Line 6") {
-		t.Errorf("expected line 6 reference (1-indexed)")
+	if !contains(exported, "### Line 6 (new)") {
+		t.Errorf("expected new-side line 6 reference")
 	}
 
-	if !contains(exported, "Lines 11-16") {
-		t.Errorf("expected range 11-16 reference (1-indexed)")
+	if !contains(exported, "### Lines 11-16 (old)") {
+		t.Errorf("expected old-side range 11-16 reference")
 	}
 
 	if !contains(exported, "Single line comment") {
@@ -460,8 +566,8 @@ func TestQuitFunctionality(t *testing.T) {
 // Helper function for string contains check
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && s[:len(substr)] == substr ||
-		   len(s) > len(substr) && s[len(s)-len(substr):] == substr ||
-		   containsMiddle(s, substr)
+		len(s) > len(substr) && s[len(s)-len(substr):] == substr ||
+		containsMiddle(s, substr)
 }
 
 func containsMiddle(s, substr string) bool {
@@ -509,40 +615,38 @@ func TestEdgeCases(t *testing.T) {
 }
 
 func TestCommentKeyGeneration(t *testing.T) {
-	mock := testutil.NewMockGitClient().
-		WithIsRepo(true).
-		WithChangedFiles([]string{"file1.go", "file2.go"})
-
-	m := createTestModel(mock)
+	m := createTestModelWithDiff(t)
 
 	// Test comment key generation bounds checking
 	m.currentIndex = -1
-	key := m.getCommentKey(5)
-	if key != "" {
-		t.Errorf("expected empty key for invalid currentIndex, got %s", key)
+	if _, ok := m.getCommentKey(5); ok {
+		t.Errorf("expected no key for invalid currentIndex")
 	}
 
 	m.currentIndex = 10 // Beyond array bounds
-	key = m.getCommentKey(5)
-	if key != "" {
-		t.Errorf("expected empty key for out of bounds currentIndex, got %s", key)
+	if _, ok := m.getCommentKey(5); ok {
+		t.Errorf("expected no key for out of bounds currentIndex")
 	}
 
 	// Reset to valid index
 	m.currentIndex = 0
 
 	// Test valid key generation
-	key = m.getCommentKey(5)
-	expected := "file1.go:5"
-	if key != expected {
-		t.Errorf("expected key %s, got %s", expected, key)
+	key, ok := m.getCommentKey(5)
+	expected := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 10, EndLine: 10}
+	if !ok || key != expected {
+		t.Errorf("expected key %+v, got %+v", expected, key)
+	}
+
+	// Rows beyond the diff have no line numbers
+	if _, ok := m.getCommentKey(100); ok {
+		t.Errorf("expected no key for row beyond the diff")
 	}
 
 	// Test range key bounds checking
 	m.currentIndex = -1
-	rangeKey := m.getCommentKeyForRange(5, 10)
-	if rangeKey != "" {
-		t.Errorf("expected empty range key for invalid currentIndex, got %s", rangeKey)
+	if _, ok := m.getCommentKeyForRange(5, 10); ok {
+		t.Errorf("expected no range key for invalid currentIndex")
 	}
 }
 
@@ -577,4 +681,1514 @@ type mockError struct {
 
 func (e *mockError) Error() string {
 	return e.message
-}
\ No newline at end of file
+}
+func TestDiffModeInHeader(t *testing.T) {
+	mock := testutil.NewMockGitClient().
+		WithIsRepo(true).
+		WithChangedFiles([]string{"file1.go"}).
+		WithFileDiff("file1.go", sampleDiff)
+
+	m, err := newWithGitClientAndLogger(mock, Options{Mode: git.Staged()}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The mode is passed through to git operations
+	if mock.LastMode() != git.Staged() {
+		t.Errorf("expected staged mode to be used for git operations, got %v", mock.LastMode())
+	}
+
+	updatedModel, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updatedModel.(model)
+	if view := m.View(); !contains(view, "staged") {
+		t.Errorf("expected header to show the active mode")
+	}
+}
+
+func TestRenamedFileHeader(t *testing.T) {
+	mock := testutil.NewMockGitClient().
+		WithIsRepo(true).
+		WithChangedFileEntries([]git.ChangedFile{
+			{Path: "new name.go", OrigPath: "old.go", IndexStatus: git.StatusRenamed, WorktreeStatus: git.StatusUnmodified},
+		}).
+		WithFileDiff("new name.go", sampleDiff)
+
+	m := createTestModel(mock)
+	if err := m.loadDiff(0); err != nil {
+		t.Fatalf("failed to load diff: %v", err)
+	}
+
+	// Diffs and comments are keyed by the current path
+	if _, exists := m.diffs["new name.go"]; !exists {
+		t.Errorf("expected diff cached under the new path")
+	}
+
+	updatedModel, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updatedModel.(model)
+	if view := m.View(); !contains(view, "old.go → new name.go") {
+		t.Errorf("expected header to show the rename")
+	}
+}
+
+func TestFileListStats(t *testing.T) {
+	mock := testutil.NewMockGitClient().
+		WithIsRepo(true).
+		WithChangedFileEntries([]git.ChangedFile{
+			{Path: "file1.go", IndexStatus: git.StatusUnmodified, WorktreeStatus: git.StatusModified},
+			{Path: "new.go", IndexStatus: git.StatusUntracked, WorktreeStatus: git.StatusUntracked},
+		}).
+		WithFileDiff("file1.go", sampleDiff)
+
+	m := createTestModel(mock)
+	m.comments[commentLocation{File: "file1.go", StartLine: 11, EndLine: 11}] = []comment{{ID: 1, Body: "first"}, {ID: 2, Body: "second"}}
+
+	// Opening the file list loads line counts in the background
+	updatedModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = updatedModel.(model)
+	if stat := m.fileStat(0); stat.loaded || cmd == nil {
+		t.Fatalf("expected stats to be loaded by a command, got %+v", stat)
+	}
+	msg := cmd()
+
+	// Stats requested before a reload are stale, so they're dropped
+	stale := m
+	stale.clearFileStats()
+	updatedModel, _ = stale.Update(msg)
+	stale = updatedModel.(model)
+	if stat := stale.fileStat(0); stat.loaded {
+		t.Errorf("expected stale stats to be ignored, got %+v", stat)
+	}
+
+	updatedModel, _ = m.Update(msg)
+	m = updatedModel.(model)
+	if _, exists := m.diffs["file1.go"]; exists {
+		t.Error("expected stats to be loaded without loading the diff")
+	}
+	if cmd := m.loadFileStats(); cmd != nil {
+		t.Error("expected cached stats not to be loaded again")
+	}
+
+	stat := m.fileStat(0)
+	if stat.added != 2 || stat.deleted != 1 || stat.comments != 2 || !stat.loaded {
+		t.Errorf("unexpected stats for file1.go: %+v", stat)
+	}
+
+	summary := m.fileListSummary()
+	expected := "2 files · +2 -1 · 2 comments (1 modified, 1 untracked)"
+	if summary != expected {
+		t.Errorf("expected summary %q, got %q", expected, summary)
+	}
+
+	view := m.renderFileList()
+	for _, want := range []string{"modified", "untracked", "+2", "-1", "💬 2"} {
+		if !contains(view, want) {
+			t.Errorf("expected file list to contain %q", want)
+		}
+	}
+}
+
+func TestFileListTree(t *testing.T) {
+	mock := testutil.NewMockGitClient().
+		WithIsRepo(true).
+		WithChangedFiles([]string{"b.go", "internal/ui/view.go", "internal/ui/model.go", "internal/git/client.go", "README.md", "cmd/app/main.go"})
+
+	m, err := newWithGitClientAndLogger(mock, Options{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Files are sorted into tree order, directories first
+	var paths []string
+	for _, file := range m.changedFiles {
+		paths = append(paths, file.Path)
+	}
+	expectedOrder := "cmd/app/main.go internal/git/client.go internal/ui/model.go internal/ui/view.go README.md b.go"
+	if got := strings.Join(paths, " "); got != expectedOrder {
+		t.Errorf("expected order %q, got %q", expectedOrder, got)
+	}
+
+	entryNames := func() string {
+		var names []string
+		for _, entry := range m.fileListEntries() {
+			names = append(names, fmt.Sprintf("%d:%s", entry.depth, entry.node.name))
+		}
+		return strings.Join(names, " ")
+	}
+
+	// Single-child directory chains are compacted
+	expected := "0:cmd/app 1:main.go 0:internal 1:git 2:client.go 1:ui 2:model.go 2:view.go 0:README.md 0:b.go"
+	if got := entryNames(); got != expected {
+		t.Errorf("expected entries %q, got %q", expected, got)
+	}
+
+	// Aggregate counts cover every file below a directory
+	internal := m.fileListEntries()[2].node
+	if _, files := m.dirStat(internal); files != 3 {
+		t.Errorf("expected 3 files under internal, got %d", files)
+	}
+
+	// Collapse the internal directory with h
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = updatedModel.(model)
+	m.fileListCursor = 2
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("h")})
+	m = updatedModel.(model)
+	expected = "0:cmd/app 1:main.go 0:internal 0:README.md 0:b.go"
+	if got := entryNames(); got != expected {
+		t.Errorf("expected collapsed entries %q, got %q", expected, got)
+	}
+
+	// Expand it again with l, then select a nested file
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	m = updatedModel.(model)
+	m.fileListCursor = 7
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if m.fileListMode || m.currentFile() != "internal/ui/view.go" {
+		t.Errorf("expected internal/ui/view.go to be selected, got %q", m.currentFile())
+	}
+
+	// Reopening the list expands collapsed parents and places the cursor on the current file
+	m.collapsedDirs["internal"] = true
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = updatedModel.(model)
+	if m.fileListCursor != 7 || m.collapsedDirs["internal"] {
+		t.Errorf("expected cursor on the current file with parents expanded, got %d", m.fileListCursor)
+	}
+}
+
+func TestFileListFuzzyFilter(t *testing.T) {
+	mock := testutil.NewMockGitClient().
+		WithIsRepo(true).
+		WithChangedFiles([]string{"cmd/app/main.go", "internal/ui/model.go", "internal/ui/view.go", "internal/diff/parser.go", "README.md"})
+
+	m, err := newWithGitClientAndLogger(mock, Options{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	keys := func(s string) {
+		for _, r := range s {
+			updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+			m = updatedModel.(model)
+		}
+	}
+
+	// Open the file list and the filter
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = updatedModel.(model)
+	keys("/")
+	if !m.fileFilterMode {
+		t.Fatalf("expected filter mode after '/'")
+	}
+
+	// Typing narrows the list; j and k are filter text rather than navigation
+	keys("mdl")
+	entries := m.fileListEntries()
+	if len(entries) != 1 || entries[0].node.path != "internal/ui/model.go" {
+		t.Fatalf("expected only model.go to match 'mdl', got %d entries", len(entries))
+	}
+	if got := fmt.Sprint(entries[0].matches); got != "[12 14 16]" {
+		t.Errorf("expected matched positions [12 14 16], got %s", got)
+	}
+
+	// Matches in the file name rank above matches spread across the path
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	m = updatedModel.(model)
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	m = updatedModel.(model)
+	keys("ai")
+	entries = m.fileListEntries()
+	if len(entries) == 0 || entries[0].node.path != "cmd/app/main.go" {
+		t.Fatalf("expected main.go to be the top match for 'mai'")
+	}
+
+	// Enter jumps to the top match and closes the list
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if m.fileListMode || m.fileFilterMode || m.currentFile() != "cmd/app/main.go" {
+		t.Errorf("expected cmd/app/main.go to be opened, got %q", m.currentFile())
+	}
+	if m.fileFilter.Value() != "" {
+		t.Errorf("expected filter to be cleared after selection")
+	}
+
+	// Esc clears the filter and returns to the tree
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = updatedModel.(model)
+	keys("/zzz")
+	if len(m.fileListEntries()) != 0 {
+		t.Errorf("expected no matches for 'zzz'")
+	}
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updatedModel.(model)
+	if m.fileFilterMode || !m.fileListMode || len(m.fileListEntries()) != 9 {
+		t.Errorf("expected esc to restore the full tree")
+	}
+}
+
+func TestFileListFilterEnterOnDirectory(t *testing.T) {
+	mock := testutil.NewMockGitClient().
+		WithIsRepo(true).
+		WithChangedFiles([]string{"internal/a.go", "internal/b.go"})
+
+	m, err := newWithGitClientAndLogger(mock, Options{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m.width, m.height, m.ready = 80, 24, true
+
+	// With an empty query the tree is listed, so enter lands on the internal/ directory
+	sendKeys(t, &m, "tab", "/")
+	if entry := m.fileListEntries()[m.fileListCursor]; !entry.node.isDir() {
+		t.Fatalf("expected the directory to be highlighted, got %q", entry.node.path)
+	}
+	sendKeys(t, &m, "enter")
+
+	if m.currentIndex != 0 || !m.fileListMode || m.fileFilterMode || !m.collapsedDirs["internal"] {
+		t.Errorf("expected enter to collapse the directory, got index %d collapsed %v", m.currentIndex, m.collapsedDirs)
+	}
+	m.View()
+}
+
+func TestSplitView(t *testing.T) {
+	m := createTestModelWithDiff(t)
+	updatedModel, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = updatedModel.(model)
+
+	// Deletions line up with the additions that replace them
+	fd := m.diffs["file1.go"]
+	if len(fd.splitRows) != 9 {
+		t.Fatalf("expected 9 side-by-side rows, got %d", len(fd.splitRows))
+	}
+	if got := [2]int{fd.splitRows[6].Number(diff.SideOld), fd.splitRows[6].Number(diff.SideNew)}; got != [2]int{11, 11} {
+		t.Errorf("expected old 11 paired with new 11, got %v", got)
+	}
+	if fd.splitRows[7].Left != nil || fd.splitRows[7].Number(diff.SideNew) != 12 {
+		t.Errorf("expected new 12 to have no old counterpart")
+	}
+
+	// Toggling keeps the cursor on the same line
+	m.cursorLine = 9 // context, old 12 / new 13
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
+	m = updatedModel.(model)
+	if !m.splitView || m.cursorLine != 8 {
+		t.Fatalf("expected split view with cursor on row 8, got split=%v row=%d", m.splitView, m.cursorLine)
+	}
+	if !strings.Contains(m.View(), " │▶") {
+		t.Errorf("expected the cursor marker on the new column")
+	}
+
+	// Comments attach to the column the cursor is on
+	m.cursorLine = 6
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("h")})
+	m = updatedModel.(model)
+	loc, ok := m.getCommentKey(m.cursorLine)
+	if !ok || loc.Side != diff.SideOld || loc.StartLine != 11 {
+		t.Errorf("expected old line 11, got %+v (ok=%v)", loc, ok)
+	}
+	m.cursorLine = 7
+	if _, ok := m.getCommentKey(m.cursorLine); ok {
+		t.Errorf("expected no comment target on the empty old column")
+	}
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	m = updatedModel.(model)
+	if loc, ok := m.getCommentKey(m.cursorLine); !ok || loc.Side != diff.SideNew || loc.StartLine != 12 {
+		t.Errorf("expected new line 12, got %+v (ok=%v)", loc, ok)
+	}
+
+	// Toggling back maps the cursor to the unified row
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
+	m = updatedModel.(model)
+	if m.splitView || m.cursorLine != 8 {
+		t.Errorf("expected unified view with cursor on row 8, got split=%v row=%d", m.splitView, m.cursorLine)
+	}
+}
+
+func TestLineNumberGutter(t *testing.T) {
+	m := createTestModelWithDiff(t)
+	updatedModel, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 40})
+	m = updatedModel.(model)
+
+	// gutterColumns returns the column of the gutter separator on each diff row in the view
+	gutterColumns := func() map[string]int {
+		columns := make(map[string]int)
+		for _, line := range strings.Split(ansi.Strip(m.View()), "\n") {
+			for _, numbers := range []string{"10 10", "11   ", "   11", "   12", "12 13"} {
+				if idx := strings.Index(line, numbers+" │"); idx >= 0 {
+					columns[numbers] = ansi.StringWidth(line[:idx])
+				}
+			}
+		}
+		return columns
+	}
+
+	for _, cursor := range []int{5, 7} {
+		m.cursorLine = cursor
+		columns := gutterColumns()
+		if len(columns) != 5 {
+			t.Fatalf("expected a gutter on every content row, got %v", columns)
+		}
+		for numbers, column := range columns {
+			if column != columns["10 10"] {
+				t.Errorf("cursor on row %d: gutter %q at column %d, expected %d", cursor, numbers, column, columns["10 10"])
+			}
+		}
+	}
+
+	// Selection highlighting keeps the gutter aligned too
+	m.selectionMode = true
+	m.selectionStart = 6
+	m.cursorLine = 7
+	columns := gutterColumns()
+	if columns["11   "] != columns["10 10"] || columns["   11"] != columns["10 10"] {
+		t.Errorf("expected selected rows to stay aligned, got %v", columns)
+	}
+
+	// Rows are re-rendered for the new width after a resize
+	updatedModel, _ = m.Update(tea.WindowSizeMsg{Width: 60, Height: 40})
+	m = updatedModel.(model)
+	for _, line := range strings.Split(m.View(), "\n") {
+		if width := ansi.StringWidth(line); width > 60 {
+			t.Errorf("expected rows to fit the resized terminal, got width %d: %q", width, ansi.Strip(line))
+		}
+	}
+	if len(gutterColumns()) != 5 {
+		t.Errorf("expected the gutter to survive a resize")
+	}
+}
+
+func TestReviewSessionResume(t *testing.T) {
+	newMock := func(gitDir string) *testutil.MockGitClient {
+		return testutil.NewMockGitClient().
+			WithIsRepo(true).
+			WithChangedFiles([]string{"file1.go", "file2.go"}).
+			WithFileDiff("file1.go", sampleDiff).
+			WithFileDiff("file2.go", sampleDiff).
+			WithRepoInfo(git.RepoInfo{GitDir: gitDir, Branch: "main", Head: "abc123"})
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	gitDir := t.TempDir()
+
+	m, err := newWithGitClientAndLogger(newMock(gitDir), Options{}, logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.pendingSession != nil {
+		t.Fatalf("expected nothing to resume on the first run")
+	}
+
+	// Comment on file2.go, mark it reviewed and quit with the cursor on new line 13
+	sendKeys(t, &m, "n")
+	m.cursorLine = 8
+	sendKeys(t, &m, "c")
+	m.commentInput.SetValue("Check this")
+	sendKeys(t, &m, "ctrl+s", "m")
+	m.cursorLine = 9
+	sendKeys(t, &m, "q")
+
+	// The next launch offers to resume, without overwriting the saved review in the meantime
+	m, err = newWithGitClientAndLogger(newMock(gitDir), Options{}, logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.pendingSession == nil {
+		t.Fatalf("expected the saved review to be offered")
+	}
+	if !strings.Contains(m.View(), "1 comments · 1 files reviewed") {
+		t.Errorf("expected the resume prompt to summarise the saved review")
+	}
+	sendKeys(t, &m, "j")
+	if m.pendingSession == nil || m.cursorLine != 0 {
+		t.Errorf("expected keys other than y/n to be ignored by the prompt")
+	}
+
+	sendKeys(t, &m, "y")
+	if m.pendingSession != nil {
+		t.Fatalf("expected the prompt to close")
+	}
+	loc := commentLocation{File: "file2.go", Side: diff.SideNew, StartLine: 12, EndLine: 12}
+	if got := m.comments[loc]; len(got) != 1 || got[0].Body != "Check this" {
+		t.Errorf("expected the comment to be restored at %v, got %v", loc, m.comments)
+	}
+	if !m.reviewed["file2.go"] || m.reviewed["file1.go"] {
+		t.Errorf("expected only file2.go to be reviewed, got %v", m.reviewed)
+	}
+	if m.currentFile() != "file2.go" || m.cursorLine != 9 {
+		t.Errorf("expected cursor restored to file2.go row 9, got %s row %d", m.currentFile(), m.cursorLine)
+	}
+
+	// Declining starts a fresh review
+	m, _ = newWithGitClientAndLogger(newMock(gitDir), Options{}, logger)
+	sendKeys(t, &m, "n")
+	if m.pendingSession != nil || len(m.comments) != 0 || m.currentFile() != "file1.go" {
+		t.Errorf("expected a fresh review after declining")
+	}
+}
+
+func TestExportAfterResume(t *testing.T) {
+	newMock := func(gitDir, file2Diff string) *testutil.MockGitClient {
+		return testutil.NewMockGitClient().
+			WithIsRepo(true).
+			WithChangedFiles([]string{"file1.go", "file2.go"}).
+			WithFileDiff("file1.go", sampleDiff).
+			WithFileDiff("file2.go", file2Diff).
+			WithRepoInfo(git.RepoInfo{GitDir: gitDir, Branch: "main", Head: "abc123"})
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	gitDir := t.TempDir()
+
+	// Comment on two lines of file2.go, then quit from file1.go so file2.go isn't reopened
+	m, err := newWithGitClientAndLogger(newMock(gitDir, sampleDiff), Options{}, logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sendKeys(t, &m, "n")
+	for _, comment := range []struct {
+		row  int
+		body string
+	}{{7, "Why 3?"}, {8, "Unused"}} {
+		m.cursorLine = comment.row
+		sendKeys(t, &m, "c")
+		m.commentInput.SetValue(comment.body)
+		sendKeys(t, &m, "ctrl+s")
+	}
+	sendKeys(t, &m, "p", "q")
+
+	// The lines moved down by one before the review is resumed and exported
+	shifted := strings.Replace(sampleDiff, "@@ -10,3 +10,4 @@", "@@ -11,3 +11,4 @@", 1)
+	m, err = newWithGitClientAndLogger(newMock(gitDir, shifted), Options{}, logger)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sendKeys(t, &m, "y")
+	m.export = exportOptions{code: true}
+	export := exportText(t, &m)
+	for _, want := range []string{
+		"### Line 12 (new)\n```go\n\tb := 3\n```\n- Why 3?\n",
+		"### Line 13 (new)\n```go\n\tc := 4\n```\n- Unused\n",
+	} {
+		if strings.Count(export, want) != 1 {
+			t.Errorf("expected %q once in export, got:\n%s", want, export)
+		}
+	}
+}
+
+func TestCommentsReanchorWhenDiffChanges(t *testing.T) {
+	mock := testutil.NewMockGitClient().
+		WithIsRepo(true).
+		WithChangedFiles([]string{"file1.go"}).
+		WithFileDiff("file1.go", sampleDiff)
+	m := createTestModel(mock)
+	if err := m.loadDiff(0); err != nil {
+		t.Fatalf("failed to load diff: %v", err)
+	}
+
+	// Comment on the added "c := 4" (new line 12) and the deleted "b := 2" (old line 11)
+	added := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 12, EndLine: 12}
+	deleted := commentLocation{File: "file1.go", Side: diff.SideOld, StartLine: 11, EndLine: 11}
+	m.addComment(added, "Why 4?", severityNone)
+	m.addComment(deleted, "Keep this", severityNone)
+
+	// The agent adds two lines above and rewrites the deleted line
+	mock.WithFileDiff("file1.go", `diff --git a/file1.go b/file1.go
+index 1111111..3333333 100644
+--- a/file1.go
++++ b/file1.go
+@@ -10,3 +10,6 @@ func main() {
++	// setup
++	setup()
+ 	a := 1
+-	b := 7
++	b := 3
++	c := 4
+ 	return
+`)
+	delete(m.diffs, "file1.go")
+	if err := m.loadDiff(0); err != nil {
+		t.Fatalf("failed to reload diff: %v", err)
+	}
+
+	moved := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 14, EndLine: 14}
+	if got := m.comments[moved]; len(got) != 1 || got[0].Body != "Why 4?" {
+		t.Errorf("expected the comment to follow its code to new line 14, got %v", m.comments)
+	}
+	if _, exists := m.comments[added]; exists {
+		t.Errorf("expected nothing left at the old location")
+	}
+	if !m.outdated[deleted] || m.outdated[moved] {
+		t.Errorf("expected only the comment on the rewritten line to be outdated, got %v", m.outdated)
+	}
+
+	// Outdated comments are called out in the view and the export rather than shown on a line
+	m.ready, m.width = true, 120
+	m.viewport.Width, m.viewport.Height = 118, 30
+	m.setViewportContent(m.diffs["file1.go"])
+	if view := m.View(); !strings.Contains(view, "⚠ 1 outdated") || !strings.Contains(view, "⚠ outdated [line 11 (old)] Keep this") {
+		t.Errorf("expected the outdated comment to be flagged in the view")
+	}
+	if export := exportText(t, &m); !strings.Contains(export, "### Line 11 (old) (outdated)") {
+		t.Errorf("expected the export to flag the outdated comment, got:\n%s", export)
+	}
+}
+
+func TestAutoRefreshOnFileChanges(t *testing.T) {
+	mock := testutil.NewMockGitClient().
+		WithIsRepo(true).
+		WithChangedFiles([]string{"b.go", "c.go"}).
+		WithFileDiff("b.go", sampleDiff).
+		WithFileDiff("c.go", sampleDiff).
+		WithFingerprint("v1")
+
+	m, err := newWithGitClientAndLogger(mock, Options{WatchInterval: time.Millisecond}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Init() == nil {
+		t.Fatalf("expected the watcher to start")
+	}
+
+	// Review c.go with the cursor on new line 13 and a comment on new line 12
+	m.currentIndex = 1
+	if err := m.loadDiff(1); err != nil {
+		t.Fatalf("failed to load diff: %v", err)
+	}
+	m.addComment(commentLocation{File: "c.go", Side: diff.SideNew, StartLine: 12, EndLine: 12}, "Why 4?", severityNone)
+	m.cursorLine = 9
+
+	// Nothing changed on disk
+	updatedModel, cmd := m.Update(fingerprintMsg{fingerprint: "v1"})
+	m = updatedModel.(model)
+	if cmd == nil || m.statusMessage != "" {
+		t.Errorf("expected no reload while the fingerprint is unchanged, but keep watching")
+	}
+
+	// The agent adds a.go, deletes b.go and inserts a line at the top of c.go
+	mock.WithChangedFiles([]string{"a.go", "c.go"}).
+		WithFileDiff("a.go", sampleDiff).
+		WithFileDiff("c.go", strings.Replace(sampleDiff, "@@ -10,3 +10,4 @@ func main() {\n", "@@ -10,3 +10,5 @@ func main() {\n+\tsetup()\n", 1))
+	updatedModel, cmd = m.Update(fingerprintMsg{fingerprint: "v2"})
+	m = updatedModel.(model)
+
+	if cmd == nil {
+		t.Errorf("expected to keep watching after a reload")
+	}
+	if m.currentFile() != "c.go" || m.currentIndex != 1 {
+		t.Errorf("expected to stay on c.go, got %q", m.currentFile())
+	}
+	if line := m.lineNumber(m.cursorLine, diff.SideNew); line != 14 {
+		t.Errorf("expected the cursor to follow its line to new line 14, got %d", line)
+	}
+	if got := m.comments[commentLocation{File: "c.go", Side: diff.SideNew, StartLine: 13, EndLine: 13}]; len(got) != 1 {
+		t.Errorf("expected the comment to be kept and moved to new line 13, got %v", m.comments)
+	}
+	if !strings.Contains(m.statusMessage, "added a.go") || !strings.Contains(m.statusMessage, "removed b.go") {
+		t.Errorf("expected added and removed files to be announced, got %q", m.statusMessage)
+	}
+
+	// Failed checks don't stop the watcher
+	updatedModel, cmd = m.Update(fingerprintMsg{err: fmt.Errorf("index.lock exists")})
+	m = updatedModel.(model)
+	if cmd == nil || m.currentFile() != "c.go" {
+		t.Errorf("expected watching to continue after an error")
+	}
+}
+
+func TestAutoRefreshKeepsSelection(t *testing.T) {
+	mock := testutil.NewMockGitClient().
+		WithIsRepo(true).
+		WithChangedFiles([]string{"c.go"}).
+		WithFileDiff("c.go", sampleDiff).
+		WithFingerprint("v1")
+
+	m, err := newWithGitClientAndLogger(mock, Options{WatchInterval: time.Millisecond}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Select new lines 11-12
+	m.cursorLine = 7
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("v")})
+	m = updatedModel.(model)
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("j")})
+	m = updatedModel.(model)
+
+	// A line is inserted above the selection while it's open
+	mock.WithFileDiff("c.go", strings.Replace(sampleDiff, "@@ -10,3 +10,4 @@ func main() {\n", "@@ -10,3 +10,5 @@ func main() {\n+\tsetup()\n", 1))
+	updatedModel, _ = m.Update(fingerprintMsg{fingerprint: "v2"})
+	m = updatedModel.(model)
+
+	if !m.selectionMode {
+		t.Fatal("expected the selection to stay open across the reload")
+	}
+	start, end := m.getSelectionRange()
+	if first, last := m.lineNumber(start, diff.SideNew), m.lineNumber(end, diff.SideNew); first != 12 || last != 13 {
+		t.Errorf("expected the selection to follow its lines to new lines 12-13, got %d-%d", first, last)
+	}
+}
+
+func TestManualRefresh(t *testing.T) {
+	mock := testutil.NewMockGitClient().
+		WithIsRepo(true).
+		WithChangedFiles([]string{"b.go", "c.go"}).
+		WithFileDiff("b.go", sampleDiff).
+		WithFileDiff("c.go", sampleDiff)
+	m := createTestModel(mock)
+	m.loadFileStats()
+	m.currentIndex = 1
+	if err := m.loadDiff(1); err != nil {
+		t.Fatalf("failed to load diff: %v", err)
+	}
+	cachedB, cachedC := m.diffs["b.go"], m.diffs["c.go"]
+
+	// A file added before the current one shifts its index, but the selection follows the name
+	mock.WithChangedFiles([]string{"a.go", "b.go", "c.go"}).WithFileDiff("a.go", sampleDiff)
+	sendKeys(t, &m, "r")
+	if m.currentFile() != "c.go" || m.currentIndex != 2 {
+		t.Errorf("expected to stay on c.go at index 2, got %q at %d", m.currentFile(), m.currentIndex)
+	}
+	if m.diffs["b.go"] != cachedB {
+		t.Errorf("expected r to keep the cached diffs of other files")
+	}
+	if m.diffs["c.go"] == cachedC {
+		t.Errorf("expected r to reload the current file's diff")
+	}
+	if m.statusMessage != "⟳ Reloaded · added a.go" {
+		t.Errorf("unexpected status message %q", m.statusMessage)
+	}
+
+	// R reloads every diff
+	sendKeys(t, &m, "R")
+	if _, exists := m.diffs["b.go"]; exists {
+		t.Errorf("expected R to discard every cached diff")
+	}
+	if _, exists := m.diffs["c.go"]; !exists || m.currentFile() != "c.go" {
+		t.Errorf("expected the current file to be reloaded")
+	}
+
+	// Failures are reported without losing the review
+	mock.WithFilesError(fmt.Errorf("git exploded"))
+	sendKeys(t, &m, "r")
+	if !strings.Contains(m.statusMessage, "git exploded") || m.currentFile() != "c.go" {
+		t.Errorf("expected the failure in the status bar, got %q", m.statusMessage)
+	}
+}
+
+func TestEditAndDeleteComments(t *testing.T) {
+	m := createTestModelWithDiff(t)
+
+	// Two comments on the added "b := 3" line and one on a range ending below it
+	line11 := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 11}
+	rangeLoc := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 12}
+	m.addComment(line11, "first", severityNone)
+	m.addComment(line11, "second", severityNone)
+	m.addComment(rangeLoc, "range", severityNone)
+
+	// Nothing to edit away from the comments
+	m.cursorLine = 5
+	sendKeys(t, &m, "e")
+	if m.commentMode || !strings.Contains(m.statusMessage, "No comment") {
+		t.Fatalf("expected edit to be refused with no comment under the cursor, got mode %v status %q", m.commentMode, m.statusMessage)
+	}
+
+	// Row 7 is new line 11, covered by all three comments; ] focuses the second
+	m.cursorLine = 7
+	if got := len(m.cursorComments()); got != 3 {
+		t.Fatalf("expected 3 comments under the cursor, got %d", got)
+	}
+	sendKeys(t, &m, "]", "e")
+	if !m.commentMode || m.commentInput.Value() != "second" || m.commentTarget != line11 {
+		t.Fatalf("expected to edit the second comment, got mode %v value %q target %+v", m.commentMode, m.commentInput.Value(), m.commentTarget)
+	}
+	m.commentInput.SetValue("second, edited")
+	sendKeys(t, &m, "ctrl+s")
+	if got := m.comments[line11]; len(got) != 2 || got[1].ID != 2 || got[1].Body != "second, edited" {
+		t.Errorf("expected the second comment to be edited in place, got %+v", got)
+	}
+
+	// Cancelling an edit leaves the comment alone
+	sendKeys(t, &m, "e")
+	m.commentInput.SetValue("discarded")
+	sendKeys(t, &m, "esc")
+	if got := m.comments[line11][1].Body; got != "second, edited" || m.editingComment != 0 {
+		t.Errorf("expected cancelled edit to keep the comment, got %q", got)
+	}
+
+	// Deleting asks first, and n keeps the comment
+	sendKeys(t, &m, "]", "d")
+	if m.confirmDelete != 3 {
+		t.Fatalf("expected confirmation for comment 3, got %d", m.confirmDelete)
+	}
+	m.width, m.height, m.ready = 80, 40, true
+	if view := m.View(); !strings.Contains(view, "Delete comment on lines 11-12 (new)?") {
+		t.Errorf("expected delete prompt in view, got:\n%s", view)
+	}
+	sendKeys(t, &m, "n")
+	if _, _, ok := m.findComment(3); !ok || m.confirmDelete != 0 {
+		t.Fatal("expected n to keep the comment")
+	}
+
+	// Confirming removes the comment and forgets the location once it's empty
+	sendKeys(t, &m, "d", "y")
+	if _, exists := m.comments[rangeLoc]; exists {
+		t.Errorf("expected range comment to be deleted, got %+v", m.comments[rangeLoc])
+	}
+	if _, exists := m.anchors[rangeLoc]; exists {
+		t.Error("expected anchor of deleted range comment to be dropped")
+	}
+
+	// IDs aren't reused after a delete
+	m.addComment(line11, "third", severityNone)
+	if got := m.comments[line11]; got[len(got)-1].ID != 4 {
+		t.Errorf("expected new comment to get ID 4, got %+v", got)
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	m := createTestModelWithDiff(t)
+	line11 := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 11}
+
+	// Record a review: add two comments, edit one, delete the other and mark the file reviewed
+	m.addComment(line11, "first", severityNone)
+	m.addComment(line11, "second", severityNone)
+	m.editComment(1, "first, edited", severityNone)
+	m.deleteComment(2)
+	m.toggleReviewed()
+	if len(m.history) != 5 {
+		t.Fatalf("expected 5 commands in the history, got %d", len(m.history))
+	}
+	wantComments := []string{"1 first, edited"}
+	if got := commentSummaries(m.comments[line11]); !reflect.DeepEqual(got, wantComments) || !m.reviewed["file1.go"] {
+		t.Fatalf("unexpected state after recording: comments %+v reviewed %v", got, m.reviewed)
+	}
+
+	// Replaying the history onto a fresh model reproduces the review
+	replayed := createTestModelWithDiff(t)
+	for _, cmd := range m.history {
+		cmd.do(&replayed)
+	}
+	if !reflect.DeepEqual(replayed.comments, m.comments) || !reflect.DeepEqual(replayed.reviewed, m.reviewed) {
+		t.Errorf("replay mismatch: comments %+v reviewed %v", replayed.comments, replayed.reviewed)
+	}
+
+	// Undo one step at a time back to the start
+	steps := []struct {
+		comments []string
+		reviewed bool
+	}{
+		{comments: []string{"1 first, edited"}, reviewed: false},
+		{comments: []string{"1 first, edited", "2 second"}},
+		{comments: []string{"1 first", "2 second"}},
+		{comments: []string{"1 first"}},
+		{comments: nil},
+	}
+	for i, step := range steps {
+		sendKeys(t, &m, "u")
+		if got := commentSummaries(m.comments[line11]); !reflect.DeepEqual(got, step.comments) || m.reviewed["file1.go"] != step.reviewed {
+			t.Fatalf("undo %d: expected comments %+v reviewed %v, got %+v reviewed %v", i+1, step.comments, step.reviewed, got, m.reviewed["file1.go"])
+		}
+	}
+	if _, exists := m.anchors[line11]; exists {
+		t.Error("expected anchor to be dropped once every comment is undone")
+	}
+	sendKeys(t, &m, "u")
+	if m.statusMessage != "Nothing to undo" {
+		t.Errorf("expected nothing to undo, got %q", m.statusMessage)
+	}
+
+	// Redo everything
+	for range steps {
+		sendKeys(t, &m, "ctrl+r")
+	}
+	if got := commentSummaries(m.comments[line11]); !reflect.DeepEqual(got, wantComments) || !m.reviewed["file1.go"] {
+		t.Errorf("unexpected state after redo: comments %+v reviewed %v", got, m.reviewed)
+	}
+	if _, exists := m.anchors[line11]; !exists {
+		t.Error("expected anchor to be restored by redo")
+	}
+
+	// A new change after undoing discards the undone commands
+	sendKeys(t, &m, "u", "u")
+	m.addComment(line11, "third", severityNone)
+	sendKeys(t, &m, "ctrl+r")
+	if m.statusMessage != "Nothing to redo" || len(m.history) != 4 {
+		t.Errorf("expected redo history to be discarded, got status %q and %d commands", m.statusMessage, len(m.history))
+	}
+}
+
+func TestMultiLineComments(t *testing.T) {
+	m := createTestModelWithDiff(t)
+
+	// Enter starts a new line rather than saving
+	m.cursorLine = 7
+	sendKeys(t, &m, "c", "Rename this:", "enter")
+	if !m.commentMode {
+		t.Fatal("expected enter to keep the editor open")
+	}
+	sendKeys(t, &m, "- b is unclear", "enter", "- "+strings.Repeat("very ", 60)+"long", "alt+enter")
+	if m.commentMode {
+		t.Fatal("expected alt+enter to save the comment")
+	}
+
+	loc := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 11}
+	want := "Rename this:\n- b is unclear\n- " + strings.Repeat("very ", 60) + "long"
+	if got := m.comments[loc]; len(got) != 1 || got[0].Body != want {
+		t.Fatalf("expected multi-line comment without a length limit, got %+v", got)
+	}
+
+	// Continuation lines are indented to stay inside the list item, blank lines aren't padded
+	m.comments[loc] = append(m.comments[loc], comment{ID: 2, Body: "Try:\n\n```go\nb := 3\n```\n"})
+	export := exportText(t, &m)
+	wantExport := "- Rename this:\n  - b is unclear\n  - " + strings.Repeat("very ", 60) + "long\n" +
+		"- Try:\n\n  ```go\n  b := 3\n  ```\n"
+	if !strings.Contains(export, wantExport) {
+		t.Errorf("expected indented multi-line comments in export, got:\n%s", export)
+	}
+}
+
+func TestEditorComments(t *testing.T) {
+	m := createTestModelWithDiff(t)
+
+	// The template shows the selected lines below the scissors line
+	target := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 12}
+	template := m.editorTemplate(target)
+	for _, want := range []string{editorScissors, "lines 11-12 (new)", "#    11 +\tb := 3", "#    12 +\tc := 4"} {
+		if !strings.Contains(template, want) {
+			t.Errorf("expected template to contain %q, got:\n%s", want, template)
+		}
+	}
+	if strings.Contains(template, "b := 2") {
+		t.Errorf("expected only the selected lines in the template, got:\n%s", template)
+	}
+
+	finish := func(content string) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "comment.md")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		updatedModel, _ := m.Update(editorFinishedMsg{target: target, path: path})
+		m = updatedModel.(model)
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected the comment file to be removed")
+		}
+	}
+
+	// Leaving the comment empty adds nothing
+	finish(template)
+	if len(m.comments) != 0 {
+		t.Errorf("expected no comment from an empty file, got %+v", m.comments)
+	}
+
+	// Text above the scissors line becomes the comment
+	finish("\nThese two lines\n\nshould be one.\n" + template)
+	if got := m.comments[target]; len(got) != 1 || got[0].Body != "These two lines\n\nshould be one." {
+		t.Errorf("expected comment from the editor, got %+v", got)
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	t.Chdir(t.TempDir())
+	var original strings.Builder
+	for i := 1; i <= 13; i++ {
+		switch i {
+		case 10:
+			original.WriteString("\ta := 1\n")
+		case 11:
+			original.WriteString("\tb := 3\n")
+		case 12:
+			original.WriteString("\tc := 4\n")
+		default:
+			original.WriteString(fmt.Sprintf("// line %d\n", i))
+		}
+	}
+	if err := os.WriteFile("file1.go", []byte(original.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := createTestModelWithDiff(t)
+
+	// Suggestions can't replace deleted lines
+	m.cursorLine = 6
+	sendKeys(t, &m, "S")
+	if m.commentMode {
+		t.Fatal("expected suggestion on an old line to be refused")
+	}
+
+	// S starts from the selected lines
+	m.cursorLine = 7
+	sendKeys(t, &m, "v", "j", "S")
+	// The editor shows tabs as spaces, which are turned back into tabs when saved
+	if want := "```suggestion\n    b := 3\n    c := 4\n```"; !m.commentMode || m.commentInput.Value() != want {
+		t.Fatalf("expected suggestion template %q, got %q", want, m.commentInput.Value())
+	}
+	m.commentInput.SetValue("Merge these:\n```suggestion\n    b, c := 3, 4\n```")
+	sendKeys(t, &m, "ctrl+s")
+
+	loc := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 12}
+	if got := m.comments[loc]; len(got) != 1 {
+		t.Fatalf("expected suggestion at %+v, got %+v", loc, m.comments)
+	}
+	if export := exportText(t, &m); !strings.Contains(export, "- **suggestion:** Merge these:\n  ```suggestion\n  \tb, c := 3, 4\n  ```\n") {
+		t.Errorf("expected suggestion block in export, got:\n%s", export)
+	}
+
+	// Kept as S started it, the suggestion block still opens on its own line
+	m.cursorLine = 7
+	sendKeys(t, &m, "S", "ctrl+s")
+	if export := exportText(t, &m); !strings.Contains(export, "- **suggestion:**\n  ```suggestion\n  \tb := 3\n  ```\n") {
+		t.Errorf("expected template suggestion block in export, got:\n%s", export)
+	}
+	m.deleteComment(m.comments[commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 11}][0].ID)
+
+	// A refuses to apply once the file no longer matches
+	if err := os.WriteFile("file1.go", []byte(strings.Replace(original.String(), "c := 4", "c := 5", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sendKeys(t, &m, "A")
+	if !strings.Contains(m.statusMessage, "no longer applies") {
+		t.Errorf("expected conflict, got status %q", m.statusMessage)
+	}
+
+	// The suggestion still applies after the lines moved down
+	if err := os.WriteFile("file1.go", []byte("// new first line\n"+original.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sendKeys(t, &m, "A")
+	content, err := os.ReadFile("file1.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "// new first line\n" + strings.Replace(original.String(), "\tb := 3\n\tc := 4\n", "\tb, c := 3, 4\n", 1)
+	if string(content) != want {
+		t.Errorf("expected suggestion applied, status %q, got:\n%s", m.statusMessage, content)
+	}
+}
+
+func TestReplaceLines(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		start       int
+		original    []string
+		replacement []string
+		want        string
+		wantErr     bool
+	}{
+		{name: "in place", content: "a\nb\nc\n", start: 2, original: []string{"b"}, replacement: []string{"B", "B2"}, want: "a\nB\nB2\nc\n"},
+		{name: "delete", content: "a\nb\nc\n", start: 2, original: []string{"b"}, want: "a\nc\n"},
+		{name: "crlf", content: "a\r\nb\r\n", start: 2, original: []string{"b"}, replacement: []string{"B"}, want: "a\r\nB\r\n"},
+		{name: "no final newline", content: "a\nb", start: 2, original: []string{"b"}, replacement: []string{"B"}, want: "a\nB"},
+		{name: "moved", content: "x\na\nb\n", start: 2, original: []string{"b"}, replacement: []string{"B"}, want: "x\na\nB\n"},
+		{name: "ambiguous", content: "x\nb\nb\n", start: 1, original: []string{"b"}, replacement: []string{"B"}, wantErr: true},
+		{name: "changed", content: "a\nc\n", start: 2, original: []string{"b"}, replacement: []string{"B"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := replaceLines(tt.content, tt.start, tt.original, tt.replacement)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCommentSeverities(t *testing.T) {
+	m := createTestModelWithDiff(t)
+	m.width, m.height, m.ready = 120, 40, true
+
+	// A prefix tags the comment
+	m.cursorLine = 7
+	sendKeys(t, &m, "c", "nit: rename b", "ctrl+s")
+	line11 := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 11}
+	if got := m.comments[line11]; len(got) != 1 || got[0].Severity != severityNit || got[0].Body != "rename b" {
+		t.Fatalf("expected nit comment from prefix, got %+v", got)
+	}
+
+	// Tab picks a severity, shown as a badge while writing
+	m.cursorLine = 8
+	sendKeys(t, &m, "c", "tab")
+	if view := m.View(); !strings.Contains(view, "BLOCKING") {
+		t.Errorf("expected picked severity badge in the comment editor, got:\n%s", view)
+	}
+	sendKeys(t, &m, "c is never used", "ctrl+s")
+	line12 := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 12, EndLine: 12}
+	if got := m.comments[line12]; len(got) != 1 || got[0].Severity != severityBlocking {
+		t.Fatalf("expected blocking comment from picker, got %+v", got)
+	}
+
+	// Text that only looks like a prefix isn't a tag
+	m.addComment(line12, "Note: see https://example.com", severityNone)
+	if got := m.comments[line12][1]; got.Severity != severityNone || got.Body != "Note: see https://example.com" {
+		t.Errorf("expected untagged comment, got %+v", got)
+	}
+
+	// Badges are shown on the comments
+	m.setViewportContent(m.diffs["file1.go"])
+	if view := m.renderWithCursor(); !strings.Contains(view, "NIT") || !strings.Contains(view, "BLOCKING") {
+		t.Errorf("expected severity badges on comments, got:\n%s", view)
+	}
+
+	// Export tags each comment
+	export := exportText(t, &m)
+	if !strings.Contains(export, "- **nit:** rename b\n") || !strings.Contains(export, "- **blocking:** c is never used\n") {
+		t.Errorf("expected tagged comments in export, got:\n%s", export)
+	}
+
+	// Grouped export lists blocking comments first
+	m.export = exportOptions{groupBySeverity: true}
+	export = exportText(t, &m)
+	blocking, nit, untagged := strings.Index(export, "## Blocking\n\n### File: file1.go\n\n#### Line 12 (new)\n"), strings.Index(export, "## Nit\n"), strings.Index(export, "## Untagged\n")
+	if blocking < 0 || nit < blocking || untagged < nit {
+		t.Errorf("expected comments grouped blocking, nit, untagged, got:\n%s", export)
+	}
+
+	// Filtered export only includes the chosen severities
+	m.export = exportOptions{severities: map[severity]bool{severityBlocking: true}}
+	export = exportText(t, &m)
+	if !strings.Contains(export, "c is never used") || strings.Contains(export, "rename b") || strings.Contains(export, "example.com") {
+		t.Errorf("expected only blocking comments in export, got:\n%s", export)
+	}
+	m.export = exportOptions{severities: map[severity]bool{severityPraise: true}}
+	if export := exportText(t, &m); export != "No comments to export." {
+		t.Errorf("expected nothing to export, got:\n%s", export)
+	}
+
+	// Editing keeps the severity unless it's changed, and undo restores it
+	m.cursorLine = 7
+	sendKeys(t, &m, "e")
+	if m.commentSeverity != severityNit || m.commentInput.Value() != "rename b" {
+		t.Fatalf("expected editor to start from the nit, got %v %q", m.commentSeverity, m.commentInput.Value())
+	}
+	sendKeys(t, &m, "shift+tab", "ctrl+s")
+	if got := m.comments[line11][0].Severity; got != severitySuggestion {
+		t.Errorf("expected severity changed to suggestion, got %v", got)
+	}
+	sendKeys(t, &m, "u")
+	if got := m.comments[line11][0].Severity; got != severityNit {
+		t.Errorf("expected undo to restore nit, got %v", got)
+	}
+}
+
+// commentSummaries describes comments by ID and body, e.g. "1 Use a constant"
+func commentSummaries(comments []comment) []string {
+	var summaries []string
+	for _, c := range comments {
+		summaries = append(summaries, fmt.Sprintf("%d %s", c.ID, c.Body))
+	}
+	return summaries
+}
+
+func TestCommentThreads(t *testing.T) {
+	m := createTestModelWithDiff(t)
+	m.width, m.height, m.ready = 120, 40, true
+	m.repo.User = "alice"
+	reply := func(body string) {
+		t.Helper()
+		sendKeys(t, &m, "a")
+		if !m.commentMode || m.replyingTo != 1 {
+			t.Fatalf("expected to reply to thread 1, got mode %v replying to %d", m.commentMode, m.replyingTo)
+		}
+		m.commentInput.SetValue(body)
+		sendKeys(t, &m, "ctrl+s")
+	}
+
+	line11 := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 11}
+	m.addComment(line11, "Why 3?", severityQuestion)
+	m.addComment(line11, "Add a test", severityNone)
+
+	// Replies are added to the end of their thread, even when focused on a reply
+	m.cursorLine = 7
+	reply("It's the new default")
+	sendKeys(t, &m, "]")
+	reply("Makes sense")
+	if got, want := commentSummaries(m.comments[line11]), []string{"1 Why 3?", "3 It's the new default", "4 Makes sense", "2 Add a test"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected replies grouped under their thread, got %v", got)
+	}
+	c, _, _ := m.findComment(3)
+	if c.ReplyTo != 1 || c.Author != "alice" || c.CreatedAt.IsZero() {
+		t.Errorf("expected reply by alice to comment 1 with a timestamp, got %+v", c)
+	}
+
+	view := m.renderWithCursor()
+	if !strings.Contains(view, "↳ alice: It's the new default") {
+		t.Errorf("expected reply in view, got:\n%s", view)
+	}
+	export := exportText(t, &m)
+	if !strings.Contains(export, "- **question:** Why 3?\n  - alice: It's the new default\n  - alice: Makes sense\n- Add a test\n") {
+		t.Errorf("expected replies nested in export, got:\n%s", export)
+	}
+
+	// Resolving a thread leaves it out of the export
+	m.commentFocus = 1
+	sendKeys(t, &m, "x")
+	if c, _, _ := m.findComment(1); !c.Resolved {
+		t.Fatal("expected resolving a reply to resolve its thread")
+	}
+	if view := m.renderWithCursor(); !strings.Contains(view, "✓ resolved") {
+		t.Errorf("expected resolved thread to be marked, got:\n%s", view)
+	}
+	export = exportText(t, &m)
+	if strings.Contains(export, "Why 3?") || !strings.Contains(export, "Add a test") {
+		t.Errorf("expected only open threads in export, got:\n%s", export)
+	}
+
+	// H hides resolved threads
+	sendKeys(t, &m, "H")
+	if view := m.View(); strings.Contains(view, "Why 3?") || !strings.Contains(view, "1 resolved hidden") {
+		t.Errorf("expected resolved thread to be hidden, got:\n%s", view)
+	}
+	if got := commentSummaries(m.cursorComments()); !reflect.DeepEqual(got, []string{"2 Add a test"}) {
+		t.Errorf("expected hidden threads to be skipped under the cursor, got %v", got)
+	}
+	sendKeys(t, &m, "H")
+
+	// Resolving can be undone
+	sendKeys(t, &m, "u")
+	if c, _, _ := m.findComment(1); c.Resolved {
+		t.Error("expected undo to reopen the thread")
+	}
+
+	// Deleting a thread deletes its replies, and undo restores them in place
+	m.commentFocus = 0
+	sendKeys(t, &m, "d")
+	if view := m.View(); !strings.Contains(view, "its 2 replies") {
+		t.Errorf("expected delete prompt to mention the replies, got:\n%s", view)
+	}
+	sendKeys(t, &m, "y")
+	if got := commentSummaries(m.comments[line11]); !reflect.DeepEqual(got, []string{"2 Add a test"}) {
+		t.Errorf("expected thread and replies deleted, got %v", got)
+	}
+	sendKeys(t, &m, "u")
+	if got, want := commentSummaries(m.comments[line11]), []string{"1 Why 3?", "3 It's the new default", "4 Makes sense", "2 Add a test"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected undo to restore the thread, got %v", got)
+	}
+}
+
+func TestFileAndSummaryComments(t *testing.T) {
+	m := createTestModelWithDiff(t)
+	m.width, m.height, m.ready = 120, 40, true
+	write := func(k, body string) {
+		t.Helper()
+		sendKeys(t, &m, k)
+		if !m.commentMode {
+			t.Fatalf("expected %s to open the comment editor", k)
+		}
+		m.commentInput.SetValue(body)
+		sendKeys(t, &m, "ctrl+s")
+	}
+
+	// A note on the whole file, from anywhere in it, is shown under the header
+	m.cursorLine = 8
+	write("F", "blocking: split this file")
+	fileLoc := commentLocation{File: "file1.go"}
+	if got := m.comments[fileLoc]; len(got) != 1 || got[0].Severity != severityBlocking {
+		t.Fatalf("expected blocking file note, got %+v", m.comments)
+	}
+	view := m.View()
+	header := strings.Index(view, "📄 File")
+	note := strings.Index(view, "📝 split this file")
+	diffStart := strings.Index(view, "diff --git")
+	if header < 0 || note < header || diffStart < note {
+		t.Errorf("expected file note between the header and the diff, got:\n%s", view)
+	}
+
+	// The note can be edited from the first row
+	m.cursorLine = 0
+	sendKeys(t, &m, "e")
+	if m.commentInput.Value() != "split this file" {
+		t.Errorf("expected to edit the file note, got %q", m.commentInput.Value())
+	}
+	sendKeys(t, &m, "esc")
+
+	// The summary is written with O, and O again edits it
+	write("O", "Overall, stop adding global state")
+	sendKeys(t, &m, "O")
+	if m.commentInput.Value() != "Overall, stop adding global state" {
+		t.Errorf("expected O to edit the summary, got %q", m.commentInput.Value())
+	}
+	sendKeys(t, &m, "esc")
+
+	m.addComment(commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 11}, "Use a constant", severityNone)
+	export := exportText(t, &m)
+	want := "## Summary\n\nOverall, stop adding global state\n\n" +
+		"## File: file1.go\n\n- **blocking:** split this file\n\n### Line 11 (new)\n- Use a constant\n"
+	if !strings.Contains(export, want) {
+		t.Errorf("expected summary first and the file note under its file, got:\n%s", export)
+	}
+
+	// Emptying the summary removes it
+	sendKeys(t, &m, "O")
+	m.commentInput.SetValue("")
+	sendKeys(t, &m, "ctrl+s")
+	if _, ok := m.summary(); ok {
+		t.Error("expected emptied summary to be deleted")
+	}
+	if export := exportText(t, &m); strings.Contains(export, "## Summary") {
+		t.Errorf("expected no summary in export, got:\n%s", export)
+	}
+}
+
+func TestExportCodeSnippets(t *testing.T) {
+	m := createTestModelWithDiff(t)
+	m.addComment(commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 12}, "Merge these", severityNone)
+
+	// Code is left out unless it's asked for
+	if export := exportText(t, &m); strings.Contains(export, "```") {
+		t.Errorf("expected no code without the option, got:\n%s", export)
+	}
+
+	tests := []struct {
+		name   string
+		export exportOptions
+		want   string
+	}{
+		{
+			name:   "commented lines",
+			export: exportOptions{code: true},
+			want:   "### Lines 11-12 (new)\n```go\n\tb := 3\n\tc := 4\n```\n- Merge these\n",
+		},
+		{
+			name:   "with context",
+			export: exportOptions{code: true, contextLines: 1},
+			want:   "```go\n\ta := 1\n\tb := 3\n\tc := 4\n\treturn\n```\n",
+		},
+		{
+			name:   "with diff markers",
+			export: exportOptions{code: true, diffMarkers: true},
+			want:   "```go\n+\tb := 3\n+\tc := 4\n```\n",
+		},
+		{
+			name:   "with diff markers and context",
+			export: exportOptions{code: true, contextLines: 1, diffMarkers: true},
+			want:   "```go\n-\tb := 2\n+\tb := 3\n+\tc := 4\n \treturn\n```\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.export = tt.export
+			if export := exportText(t, &m); !strings.Contains(export, tt.want) {
+				t.Errorf("expected export to contain %q, got:\n%s", tt.want, export)
+			}
+		})
+	}
+
+	// Outdated comments use the code they were written against
+	m.export = exportOptions{code: true}
+	loc := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 12}
+	m.outdated[loc] = true
+	m.diffs = make(map[string]*fileDiff)
+	if export := exportText(t, &m); !strings.Contains(export, "```go\n\tb := 3\n\tc := 4\n```\n") {
+		t.Errorf("expected outdated comment to keep its code, got:\n%s", export)
+	}
+}
+
+// exportText exports the comments of a model, failing the test if that fails
+func exportText(t *testing.T, m *model) string {
+	t.Helper()
+	content, err := m.exportComments()
+	if err != nil {
+		t.Fatalf("failed to export comments: %v", err)
+	}
+	return content
+}
+
+func TestExportFormats(t *testing.T) {
+	mock := testutil.NewMockGitClient().WithIsRepo(true).WithChangedFiles([]string{"file1.go"})
+	if _, err := newWithGitClientAndLogger(mock, Options{ExportFormat: "yaml"}, slog.New(slog.NewTextHandler(io.Discard, nil))); err == nil {
+		t.Error("expected an unknown export format to be rejected")
+	}
+
+	m := createTestModelWithDiff(t)
+	m.repo.Head = "0123456789abcdef0123456789abcdef01234567"
+	m.export = exportOptions{format: "json", code: true}
+	m.addComment(commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 11, EndLine: 12}, "c is never used", severityBlocking)
+
+	var review struct {
+		Version  int    `json:"version"`
+		Commit   string `json:"commit"`
+		Comments []struct {
+			File      string `json:"file"`
+			Side      string `json:"side"`
+			StartLine int    `json:"start_line"`
+			EndLine   int    `json:"end_line"`
+			Severity  string `json:"severity"`
+			Body      string `json:"body"`
+			Snippet   string `json:"snippet"`
+		} `json:"comments"`
+	}
+	if err := json.Unmarshal([]byte(exportText(t, &m)), &review); err != nil {
+		t.Fatalf("expected JSON export, got %v", err)
+	}
+	if review.Version != 1 || review.Commit != m.repo.Head || len(review.Comments) != 1 {
+		t.Fatalf("unexpected review: %+v", review)
+	}
+	c := review.Comments[0]
+	if c.File != "file1.go" || c.Side != "new" || c.StartLine != 11 || c.EndLine != 12 || c.Severity != "blocking" || c.Body != "c is never used" || c.Snippet != "\tb := 3\n\tc := 4" {
+		t.Errorf("unexpected comment: %+v", c)
+	}
+
+	// Reviewing a range exports the mode and the commit its head resolves to rather than HEAD
+	m.mode = git.DiffMode{Kind: git.ModeRange, Base: "main", Head: "feature"}
+	m.gitClient = testutil.NewMockGitClient().WithRevision("feature", "fedcba9876543210fedcba9876543210fedcba98")
+	var ranged struct {
+		Mode   git.DiffMode `json:"mode"`
+		Commit string       `json:"commit"`
+	}
+	if err := json.Unmarshal([]byte(exportText(t, &m)), &ranged); err != nil {
+		t.Fatalf("expected JSON export, got %v", err)
+	}
+	if ranged.Mode != m.mode || ranged.Commit != "fedcba9876543210fedcba9876543210fedcba98" {
+		t.Errorf("expected the range and its head commit, got %+v", ranged)
+	}
+
+	// SARIF regions quote only the commented lines, even when the code is exported with context and markers
+	m.export = exportOptions{format: "sarif", code: true, contextLines: 2, diffMarkers: true}
+	var sarif struct {
+		Runs []struct {
+			Results []struct {
+				Locations []struct {
+					PhysicalLocation struct {
+						Region struct {
+							Snippet struct {
+								Text string `json:"text"`
+							} `json:"snippet"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal([]byte(exportText(t, &m)), &sarif); err != nil {
+		t.Fatalf("expected SARIF export, got %v", err)
+	}
+	if len(sarif.Runs) != 1 || len(sarif.Runs[0].Results) != 1 {
+		t.Fatalf("expected a single result, got %+v", sarif)
+	}
+	if got := sarif.Runs[0].Results[0].Locations[0].PhysicalLocation.Region.Snippet.Text; got != "\tb := 3\n\tc := 4" {
+		t.Errorf("expected snippet of the commented lines alone, got %q", got)
+	}
+	m.export = exportOptions{format: "json", code: true}
+
+	// A revision that no longer resolves fails the export rather than naming the wrong commit
+	m.mode = git.Commit("gone")
+	if _, err := m.exportComments(); err == nil || !strings.Contains(err.Error(), "unknown revision gone") {
+		t.Errorf("expected unresolved commit to fail the export, got %v", err)
+	}
+}
+
+func TestOutdatedCommentsStayInView(t *testing.T) {
+	m := createTestModelWithDiff(t)
+	m.width, m.height, m.ready = 120, 40, true
+	loc := commentLocation{File: "file1.go", Side: diff.SideNew, StartLine: 40, EndLine: 40}
+	m.comments[loc] = []comment{{ID: 1, Body: "This code moved"}}
+	m.outdated[loc] = true
+
+	// Scrolled past the first row, the comment is still listed under the header
+	m.viewport.Height = 3
+	m.viewport.SetYOffset(5)
+	m.cursorLine = 6
+	view := ansi.Strip(m.View())
+	header := strings.Index(view, "📄 File")
+	outdated := strings.Index(view, "⚠ outdated [line 40 (new)] This code moved")
+	rows := strings.Index(view, "a := 1")
+	if header < 0 || outdated < header || rows < outdated {
+		t.Errorf("expected outdated comment between the header and the diff, got:\n%s", view)
+	}
+	if strings.Count(view, "This code moved") != 1 {
+		t.Errorf("expected the outdated comment once, got:\n%s", view)
+	}
+}
+
+func TestHalfPageScrolling(t *testing.T) {
+	m := createTestModelWithDiff(t)
+	m.viewport.Height = 4
+
+	// Without a comment under the cursor, d scrolls like ctrl+d rather than deleting
+	sendKeys(t, &m, "d")
+	if m.viewport.YOffset != 2 || m.confirmDelete != 0 {
+		t.Errorf("expected d to scroll half a page, got offset %d confirm %d", m.viewport.YOffset, m.confirmDelete)
+	}
+	sendKeys(t, &m, "ctrl+d")
+	if m.viewport.YOffset != 4 {
+		t.Errorf("expected ctrl+d to scroll half a page, got offset %d", m.viewport.YOffset)
+	}
+
+	// u undoes rather than scrolling back, which is left to ctrl+u
+	sendKeys(t, &m, "u")
+	if m.viewport.YOffset != 4 || m.statusMessage != "Nothing to undo" {
+		t.Errorf("expected u to undo without scrolling, got offset %d status %q", m.viewport.YOffset, m.statusMessage)
+	}
+	sendKeys(t, &m, "ctrl+u")
+	if m.viewport.YOffset != 2 {
+		t.Errorf("expected ctrl+u to scroll half a page back, got offset %d", m.viewport.YOffset)
+	}
+}
+
+func TestNotesFitTerminal(t *testing.T) {
+	m := createTestModelWithDiff(t)
+	updatedModel, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	m = updatedModel.(model)
+	height := m.viewport.Height
+
+	// A note takes its lines from the diff rather than pushing the header off the screen
+	m.addComment(fileLocation("file1.go"), "Split this file", severityNone)
+	sendKeys(t, &m, "j")
+	if m.viewport.Height != height-1 {
+		t.Errorf("expected the diff to shrink to %d lines, got %d", height-1, m.viewport.Height)
+	}
+
+	// Notes past half the room are counted rather than shown
+	for i := range 20 {
+		m.addComment(fileLocation("file1.go"), fmt.Sprintf("Note %d", i), severityNone)
+	}
+	sendKeys(t, &m, "k")
+	view := ansi.Strip(m.View())
+	if lines := strings.Count(view, "\n") + 1; lines > 30 || !strings.Contains(view, "📄 File 1/2") {
+		t.Errorf("expected the view to fit 30 lines with its header, got %d lines:\n%s", lines, view)
+	}
+	if !strings.Contains(view, "Split this file") || !strings.Contains(view, "… 13 more notes") {
+		t.Errorf("expected the first notes and a count of the rest, got:\n%s", view)
+	}
+}
+
+func TestCommentInputFitsTerminal(t *testing.T) {
+	m := createTestModelWithDiff(t)
+	updatedModel, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	m = updatedModel.(model)
+	height := m.viewport.Height
+
+	// The diff makes room for the comment input, so the header isn't pushed off the screen
+	m.cursorLine = 7
+	sendKeys(t, &m, "c")
+	view := m.View()
+	if lines := strings.Count(view, "\n") + 1; lines > 30 || !strings.Contains(view, "📄 File 1/2") {
+		t.Errorf("expected the view to fit 30 lines with its header, got %d lines:\n%s", lines, view)
+	}
+	if want := height - lipgloss.Height(m.renderCommentInput()); m.viewport.Height != want {
+		t.Errorf("expected the diff to shrink to %d lines, got %d", want, m.viewport.Height)
+	}
+
+	// Cancelling gives the room back
+	sendKeys(t, &m, "esc")
+	if m.viewport.Height != height {
+		t.Errorf("expected the diff height restored to %d, got %d", height, m.viewport.Height)
+	}
+}
+
+func TestHelpListsReviewKeys(t *testing.T) {
+	m := createTestModelWithDiff(t)
+	m.width, m.height, m.ready = 400, 40, true
+	for _, split := range []bool{false, true} {
+		m.splitView = split
+		view := ansi.Strip(m.View())
+		for _, want := range []string{"H hide resolved", "r refresh", "R refresh all"} {
+			if !strings.Contains(view, want) {
+				t.Errorf("expected %q in the help with split view %v, got:\n%s", want, split, view)
+			}
+		}
+	}
+}
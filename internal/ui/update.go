@@ -3,69 +3,135 @@ package ui
 import (
 	"fmt"
 
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/samverrall/review-ui/internal/diff"
 )
 
-// Update handles all incoming messages and updates the model accordingly
+// verticalMarginHeight is the number of terminal lines kept free of the diff: the header
+// (4 lines), footer (3 lines), modal padding (2 lines) and a buffer (2 lines)
+const verticalMarginHeight = 4 + 3 + 2 + 2
+
+// Update handles all incoming messages and updates the model accordingly, then gives the
+// diff whatever room is left by the rest of the view
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	updated, cmd := m.update(msg)
+	next := updated.(model)
+	next.fitViewport()
+	return next, cmd
+}
+
+// update handles a single message
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-		// Handle terminal resize
-		// Account for header (4 lines), footer (3 lines), modal padding (2 lines), and buffer (2 lines)
-		headerHeight := 4
-		footerHeight := 3
-		modalPaddingHeight := 2
-		bufferHeight := 2
-		verticalMarginHeight := headerHeight + footerHeight + modalPaddingHeight + bufferHeight
-
-		if !m.ready {
-			m.viewport.Width = msg.Width
-			m.viewport.Height = msg.Height - verticalMarginHeight
-			m.ready = true
-		} else {
-			m.viewport.Width = msg.Width
-			m.viewport.Height = msg.Height - verticalMarginHeight
-		}
+		// Handle terminal resize, the height being set by fitViewport
+		// Diff rows leave room for the cursor marker so they don't shift when it moves
+		m.viewport.Width = max(msg.Width-cursorMarkerWidth, 0)
+		m.ready = true
 
-		m.width = msg.Width
+		// Headers and side-by-side columns are laid out for the terminal width
+		if msg.Width != m.width {
+			m.width = msg.Width
+			m.rerenderDiffs()
+		}
 		m.height = msg.Height
 
+	case editorFinishedMsg:
+		// Add the comment written in $EDITOR
+		m.handleEditorFinished(msg)
+		return m, nil
+
+	case fileStatsMsg:
+		// Show the line counts loaded in the background
+		m.handleFileStats(msg)
+		return m, nil
+
+	case fingerprintMsg:
+		// Reload if the files under review changed on disk
+		return m, m.handleFingerprint(msg)
+
 	case tea.KeyMsg:
+		// The resume prompt must be answered before anything else
+		if m.pendingSession != nil {
+			switch msg.String() {
+			case "y", "enter":
+				sess := m.pendingSession
+				m.pendingSession = nil
+				if err := m.resumeSession(sess); err != nil {
+					m.err = err
+				}
+			case "n", "esc":
+				// Start fresh; the saved review is replaced the next time this one is saved
+				m.pendingSession = nil
+			case "q", "ctrl+c":
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+
 		// Handle comment input mode separately
+		// Enter starts a new line. Terminals can't report shift+enter without the kitty
+		// keyboard protocol, so comments are saved with ctrl+s or alt+enter instead; many
+		// terminals can be configured to send alt+enter for shift+enter.
 		if m.commentMode {
 			switch msg.String() {
-			case "enter":
+			case "ctrl+s", "alt+enter":
 				// Save comment
-				commentText := m.commentInput.Value()
-				if commentText != "" {
-					var key string
-					// Check if this is a range comment or single line comment
-					if m.commentEndLine >= 0 && m.commentEndLine != m.commentLine {
-						// Range comment
-						key = m.getCommentKeyForRange(m.commentLine, m.commentEndLine)
-					} else {
-						// Single line comment
-						key = m.getCommentKey(m.commentLine)
+				commentText := m.restoreSuggestionTabs(m.commentTarget, m.commentInput.Value())
+				// A prefix such as "nit:" overrides the severity picked with tab
+				sev := m.commentSeverity
+				if prefixed, rest := parseSeverityPrefix(commentText); prefixed != severityNone {
+					sev, commentText = prefixed, rest
+				}
+				if m.editingComment != 0 && m.commentTarget.isSummary() && commentText == "" {
+					// The summary can't be reached with d, so emptying it deletes it
+					if m.deleteComment(m.editingComment) {
+						m.statusMessage = "🗑 Summary deleted"
+					}
+				} else if m.editingComment != 0 {
+					// An emptied comment is left unchanged; d deletes comments
+					if commentText != "" && m.editComment(m.editingComment, commentText, sev) {
+						m.statusMessage = "✓ Comment updated"
 					}
-					if key != "" {
-						m.comments[key] = append(m.comments[key], commentText)
+				} else if m.replyingTo != 0 {
+					if commentText != "" && m.addReply(m.replyingTo, commentText, sev) {
+						m.statusMessage = "↩ Reply added"
 					}
+				} else if commentText != "" {
+					m.addComment(m.commentTarget, commentText, sev)
 				}
 				// Exit comment mode
 				m.commentMode = false
+				m.editingComment = 0
+				m.replyingTo = 0
+				m.commentSeverity = severityNone
 				m.commentInput.Reset()
-				m.commentEndLine = -1
 				return m, nil
 
 			case "esc":
 				// Cancel comment
 				m.commentMode = false
+				m.editingComment = 0
+				m.replyingTo = 0
+				m.commentSeverity = severityNone
 				m.commentInput.Reset()
 				return m, nil
 
+			case "tab":
+				// Pick the next severity
+				m.commentSeverity = m.commentSeverity.next(1)
+				return m, nil
+
+			case "shift+tab":
+				// Pick the previous severity
+				m.commentSeverity = m.commentSeverity.next(-1)
+				return m, nil
+
 			default:
 				// Pass keys to text input
 				m.commentInput, cmd = m.commentInput.Update(msg)
@@ -73,16 +139,105 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		// Confirm deleting a comment
+		if m.confirmDelete != 0 {
+			switch msg.String() {
+			case "y", "enter":
+				if m.deleteComment(m.confirmDelete) {
+					m.statusMessage = "🗑 Comment deleted"
+				}
+				m.confirmDelete = 0
+				m.commentFocus = 0
+			case "n", "esc":
+				m.confirmDelete = 0
+			}
+			return m, nil
+		}
+
 		// File list mode handlers
 		if m.fileListMode {
+			entries := m.fileListEntries()
+			var entry fileListEntry
+			if m.fileListCursor >= 0 && m.fileListCursor < len(entries) {
+				entry = entries[m.fileListCursor]
+			}
+
+			// Fuzzy filter input takes all keys except navigation
+			if m.fileFilterMode {
+				switch msg.String() {
+				case "enter":
+					// Jump to the highlighted match, which is the top match unless moved
+					m.fileFilterMode = false
+					m.fileFilter.Reset()
+					m.fileFilter.Blur()
+					if entry.node == nil {
+						m.fileListCursor = m.fileListIndexOf(m.currentIndex)
+						return m, nil
+					}
+					// Without a query the whole tree is listed, so a directory may be highlighted
+					if entry.node.isDir() {
+						m.collapsedDirs[entry.node.path] = !m.collapsedDirs[entry.node.path]
+						return m, nil
+					}
+					m.currentIndex = entry.node.fileIndex
+					if err := m.loadDiff(m.currentIndex); err != nil {
+						m.err = err
+					}
+					m.fileListMode = false
+					m.saveSession()
+					return m, nil
+
+				case "esc":
+					// Clear the filter and return to the tree
+					m.fileFilterMode = false
+					m.fileFilter.Reset()
+					m.fileFilter.Blur()
+					m.fileListCursor = m.fileListIndexOf(m.currentIndex)
+					return m, nil
+
+				case "down", "ctrl+n":
+					if m.fileListCursor < len(entries)-1 {
+						m.fileListCursor++
+					}
+					return m, nil
+
+				case "up", "ctrl+p":
+					if m.fileListCursor > 0 {
+						m.fileListCursor--
+					}
+					return m, nil
+
+				default:
+					// Pass keys to the filter input and go back to the top match
+					m.fileFilter, cmd = m.fileFilter.Update(msg)
+					m.fileListCursor = 0
+					return m, cmd
+				}
+			}
+
 			switch msg.String() {
+			case "/":
+				// Open the fuzzy filter
+				m.fileFilterMode = true
+				m.fileListCursor = 0
+				m.fileFilter.Focus()
+				return m, textinput.Blink
+
 			case "enter":
-				// Select the file at fileListCursor
-				m.currentIndex = m.fileListCursor
+				if entry.node == nil {
+					return m, nil
+				}
+				// Toggle directories, select files
+				if entry.node.isDir() {
+					m.collapsedDirs[entry.node.path] = !m.collapsedDirs[entry.node.path]
+					return m, nil
+				}
+				m.currentIndex = entry.node.fileIndex
 				if err := m.loadDiff(m.currentIndex); err != nil {
 					m.err = err
 				}
 				m.fileListMode = false
+				m.saveSession()
 				return m, nil
 
 			case "esc":
@@ -90,51 +245,267 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.fileListMode = false
 				return m, nil
 
-			case "j":
+			case "j", "down":
 				// Move cursor down in file list
-				if m.fileListCursor < len(m.changedFiles)-1 {
+				if m.fileListCursor < len(entries)-1 {
 					m.fileListCursor++
 				}
 				return m, nil
 
-			case "k":
+			case "k", "up":
 				// Move cursor up in file list
 				if m.fileListCursor > 0 {
 					m.fileListCursor--
 				}
 				return m, nil
+
+			case "h", "left":
+				if entry.node == nil {
+					return m, nil
+				}
+				// Collapse an expanded directory, otherwise jump to the parent directory
+				if entry.node.isDir() && !m.collapsedDirs[entry.node.path] {
+					m.collapsedDirs[entry.node.path] = true
+					return m, nil
+				}
+				for i := m.fileListCursor - 1; i >= 0; i-- {
+					if entries[i].depth < entry.depth {
+						m.fileListCursor = i
+						break
+					}
+				}
+				return m, nil
+
+			case "l", "right":
+				// Expand a collapsed directory
+				if entry.node != nil && entry.node.isDir() {
+					delete(m.collapsedDirs, entry.node.path)
+				}
+				return m, nil
 			}
 		}
 
 		// Normal mode key handlers
 		switch msg.String() {
 		case "q", "ctrl+c":
-			// Quit the application
+			// Save the review and quit the application
+			m.saveSession()
 			return m, tea.Quit
 
+		case "r":
+			// Reload the file list and the current file's diff
+			m.refresh(false)
+			return m, nil
+
+		case "R":
+			// Reload the file list and every diff
+			m.refresh(true)
+			return m, nil
+
+		case "m":
+			// Mark the current file as reviewed, or unmark it
+			m.toggleReviewed()
+			return m, nil
+
 		case "tab":
-			// Enter file list mode
+			// Enter file list mode, loading line counts in the background
 			m.fileListMode = true
-			m.fileListCursor = m.currentIndex
-			return m, nil
+			m.fileListCursor = m.fileListIndexOf(m.currentIndex)
+			return m, m.loadFileStats()
 
 		case "c":
 			// Open comment input at current cursor line or selection
+			target, ok := m.cursorCommentTarget()
+			if !ok {
+				m.statusMessage = "✗ Comments must be on a changed or context line"
+				return m, nil
+			}
+			m.statusMessage = ""
+			m.commentTarget = target
 			m.commentMode = true
-			if m.selectionMode {
-				// Get the selection range
-				start, end := m.getSelectionRange()
-				m.commentLine = start
-				m.commentEndLine = end
-				// Exit selection mode after starting comment
-				m.selectionMode = false
-			} else {
-				// Single line comment
-				m.commentLine = m.cursorLine
-				m.commentEndLine = -1
+			// Exit selection mode after starting comment
+			m.selectionMode = false
+			m.commentInput.Focus()
+			return m, textarea.Blink
+
+		case "F":
+			// Comment on the whole file
+			if m.currentFile() == "" {
+				return m, nil
 			}
+			m.statusMessage = ""
+			m.commentTarget = fileLocation(m.currentFile())
+			m.commentMode = true
+			m.selectionMode = false
 			m.commentInput.Focus()
-			return m, textinput.Blink
+			return m, textarea.Blink
+
+		case "O":
+			// Write or edit the overall review summary
+			m.statusMessage = ""
+			m.commentTarget = summaryLocation
+			m.commentMode = true
+			m.selectionMode = false
+			if summary, ok := m.summary(); ok {
+				m.editingComment = summary.ID
+				m.commentSeverity = summary.Severity
+				m.commentInput.SetValue(summary.Body)
+			}
+			m.commentInput.Focus()
+			return m, textarea.Blink
+
+		case "S":
+			// Suggest replacement code for the cursor line or selection
+			target, ok := m.cursorCommentTarget()
+			if !ok || target.Side != diff.SideNew {
+				m.statusMessage = "✗ Suggestions must be on lines of the new version"
+				return m, nil
+			}
+			m.statusMessage = ""
+			m.commentTarget = target
+			m.commentMode = true
+			m.commentSeverity = severitySuggestion
+			m.selectionMode = false
+			m.commentInput.SetValue(m.suggestionTemplate(target))
+			m.commentInput.Focus()
+			return m, textarea.Blink
+
+		case "A":
+			// Apply the suggestion under the cursor to the working tree
+			c, _, ok := m.focusedComment()
+			if _, isSuggestion := suggestedLines(c.Body); !ok || !isSuggestion {
+				m.statusMessage = "✗ No suggestion under the cursor"
+				return m, nil
+			}
+			if err := m.applySuggestion(c.ID); err != nil {
+				m.statusMessage = fmt.Sprintf("✗ Failed to apply suggestion: %v", err)
+				return m, nil
+			}
+			if err := m.reloadChangedFiles(false); err != nil {
+				m.err = err
+			}
+			m.statusMessage = "✓ Applied suggestion"
+			return m, nil
+
+		case "E":
+			// Write a comment for the cursor line or selection in $EDITOR
+			target, ok := m.cursorCommentTarget()
+			if !ok {
+				m.statusMessage = "✗ Comments must be on a changed or context line"
+				return m, nil
+			}
+			m.statusMessage = ""
+			m.selectionMode = false
+			return m, m.openEditor(target)
+
+		case "e":
+			// Edit the comment under the cursor
+			c, loc, ok := m.focusedComment()
+			if !ok {
+				m.statusMessage = "✗ No comment under the cursor"
+				return m, nil
+			}
+			m.statusMessage = ""
+			m.commentTarget = loc
+			m.editingComment = c.ID
+			m.commentSeverity = c.Severity
+			m.commentMode = true
+			m.commentInput.SetValue(c.Body)
+			m.commentInput.Focus()
+			return m, textarea.Blink
+
+		case "a":
+			// Reply to the thread under the cursor
+			c, loc, ok := m.focusedComment()
+			if !ok {
+				m.statusMessage = "✗ No comment under the cursor"
+				return m, nil
+			}
+			root, _ := m.threadOf(c.ID)
+			m.statusMessage = ""
+			m.commentTarget = loc
+			m.replyingTo = root.ID
+			m.commentMode = true
+			m.commentInput.Focus()
+			return m, textarea.Blink
+
+		case "x":
+			// Resolve or reopen the thread under the cursor
+			c, _, ok := m.focusedComment()
+			if !ok {
+				m.statusMessage = "✗ No comment under the cursor"
+				return m, nil
+			}
+			m.toggleResolved(c.ID)
+			m.commentFocus = 0
+			return m, nil
+
+		case "H":
+			// Hide or show resolved threads
+			m.hideResolved = !m.hideResolved
+			m.commentFocus = 0
+			if m.hideResolved {
+				m.statusMessage = "Hiding resolved threads"
+			} else {
+				m.statusMessage = "Showing resolved threads"
+			}
+			return m, nil
+
+		case "d":
+			// Ask before deleting the comment under the cursor, otherwise scroll half a page down
+			c, _, ok := m.focusedComment()
+			if !ok {
+				m.viewport, cmd = m.viewport.Update(msg)
+				return m, cmd
+			}
+			m.statusMessage = ""
+			m.confirmDelete = c.ID
+			return m, nil
+
+		case "]":
+			// Focus the next comment under the cursor
+			m.cycleCommentFocus(1)
+			return m, nil
+
+		case "[":
+			// Focus the previous comment under the cursor
+			m.cycleCommentFocus(-1)
+			return m, nil
+
+		case "u":
+			// Undo the last change to the review
+			m.undo()
+			m.commentFocus = 0
+			return m, nil
+
+		case "ctrl+r":
+			// Redo the last undone change
+			m.redo()
+			m.commentFocus = 0
+			return m, nil
+
+		case "t":
+			// Toggle between the unified and side-by-side views
+			m.toggleSplitView()
+			return m, nil
+
+		case "h", "left":
+			// Move to the old column in the side-by-side view, otherwise scroll
+			if !m.splitView {
+				m.viewport, cmd = m.viewport.Update(msg)
+				return m, cmd
+			}
+			m.cursorSide = diff.SideOld
+			return m, nil
+
+		case "l", "right":
+			// Move to the new column in the side-by-side view, otherwise scroll
+			if !m.splitView {
+				m.viewport, cmd = m.viewport.Update(msg)
+				return m, cmd
+			}
+			m.cursorSide = diff.SideNew
+			return m, nil
 
 		case "v":
 			// Toggle visual selection mode
@@ -179,6 +550,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if err := m.loadDiff(m.currentIndex); err != nil {
 					m.err = err
 				}
+				m.saveSession()
 			}
 			return m, nil
 
@@ -190,6 +562,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if err := m.loadDiff(m.currentIndex); err != nil {
 					m.err = err
 				}
+				m.saveSession()
 			}
 			return m, nil
 
@@ -198,6 +571,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			totalLines := m.viewport.TotalLineCount()
 			if m.cursorLine < totalLines-1 {
 				m.cursorLine++
+				m.commentFocus = 0
 				// Auto-scroll viewport if cursor goes below visible area
 				if m.cursorLine >= m.viewport.YOffset+m.viewport.Height {
 					m.viewport.ScrollDown(1)
@@ -208,6 +582,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Move cursor up
 			if m.cursorLine > 0 {
 				m.cursorLine--
+				m.commentFocus = 0
 				// Auto-scroll viewport if cursor goes above visible area
 				if m.cursorLine < m.viewport.YOffset {
 					m.viewport.ScrollUp(1)
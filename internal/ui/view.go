@@ -3,8 +3,15 @@ package ui
 import (
 	"fmt"
 	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/samverrall/review-ui/internal/diff"
 )
 
+// Width of the margin left of every diff row, holding the cursor marker on the cursor row
+const cursorMarkerWidth = 2
+
 // renderWithCursor highlights the cursor line, selection, and displays comments
 func (m model) renderWithCursor() string {
 	// Get all lines from the viewport's total content
@@ -16,9 +23,22 @@ func (m model) renderWithCursor() string {
 		selStart, selEnd = m.getSelectionRange()
 	}
 
+	// Comments for the current file, in a stable order
+	var fileComments []commentLocation
+	for _, loc := range m.sortedCommentLocations() {
+		if loc.File == m.currentFile() {
+			fileComments = append(fileComments, loc)
+		}
+	}
+
+	// Comment e and d act on, highlighted when there's one under the cursor
+	focused := 0
+	if c, _, ok := m.focusedComment(); ok {
+		focused = c.ID
+	}
+
 	// Build output with cursor/selection highlighting and comments
 	var result []string
-	processedRanges := make(map[string]bool)
 
 	for i, line := range lines {
 		// Calculate actual line number in the diff
@@ -30,7 +50,10 @@ func (m model) renderWithCursor() string {
 		// Apply selection highlighting if in selection mode
 		if m.selectionMode && actualLineNumber >= selStart && actualLineNumber <= selEnd {
 			// Set width to fill the entire terminal width for consistency
-			line = selectionStyle.Width(m.width).Render(line)
+			line = selectionStyle.Width(m.width).Render("  " + line)
+		} else if cursorIndex >= 0 && cursorIndex < len(lines) && i == cursorIndex && m.isSplitContentRow(actualLineNumber) {
+			// In the side-by-side view only the column the cursor is on is highlighted
+			line = "▶ " + m.renderSplitCursorRow(actualLineNumber)
 		} else if cursorIndex >= 0 && cursorIndex < len(lines) && i == cursorIndex {
 			// Highlight cursor line if not in selection and add cursor indicator
 			// Only highlight if cursor is actually visible
@@ -41,54 +64,124 @@ func (m model) renderWithCursor() string {
 			// Apply cursor line style with full width
 			// The key is to set the width BEFORE rendering so it fills the entire line
 			line = cursorLineStyle.Width(m.width).Render(content)
+		} else {
+			line = "  " + line
 		}
 
 		result = append(result, line)
 
-		// Check for single line comments
-		key := m.getCommentKey(actualLineNumber)
-		if comments, exists := m.comments[key]; exists {
-			for _, comment := range comments {
-				commentLine := commentStyle.Render(fmt.Sprintf("💬 %s", comment))
-				result = append(result, commentLine)
+		// Show comments after the last line they cover, on the side they're anchored to.
+		// Outdated comments can't be placed on a line, so View lists them under the header.
+		for _, loc := range fileComments {
+			if m.outdated[loc] {
+				continue
+			}
+			line := m.lineNumber(actualLineNumber, loc.Side)
+			if line == 0 || line != loc.EndLine {
+				continue
+			}
+			// New side comments sit under the right hand column in the side-by-side view
+			indent := cursorMarkerWidth
+			if m.splitView && loc.Side == diff.SideNew {
+				indent += diff.SplitColumnWidth(m.contentWidth()) + lipgloss.Width(diff.SplitSeparator)
 			}
+			result = append(result, m.renderThreads(loc, indent, focused)...)
 		}
+	}
 
-		// Check for range comments that end at this line
-		// We need to check all possible ranges that could include this line
-		currentFile := ""
-		if m.currentIndex >= 0 && m.currentIndex < len(m.changedFiles) {
-			currentFile = m.changedFiles[m.currentIndex]
-		}
-		for rangeKey, comments := range m.comments {
-			// Parse range keys (format: "filename:start-end")
-			if strings.Contains(rangeKey, "-") && !processedRanges[rangeKey] {
-				// Check if this comment belongs to the current file
-				if !strings.HasPrefix(rangeKey, currentFile+":") {
-					continue
+	return strings.Join(result, "\n")
+}
+
+// renderThreads renders the visible threads at a location, each root comment followed by
+// its replies, indented by the given number of columns
+func (m model) renderThreads(loc commentLocation, indent, focused int) []string {
+	var lines []string
+	for _, t := range m.visibleThreads(loc) {
+		for _, c := range t.comments() {
+			var text string
+			switch {
+			case c.ReplyTo != 0:
+				text = replyLabel(c)
+			case m.outdated[loc]:
+				text = fmt.Sprintf("⚠ outdated [%s] %s", loc.label(), c.Body)
+			default:
+				icon := "💬"
+				if _, ok := suggestedLines(c.Body); ok {
+					icon = "💡"
+				} else if loc.isFileLevel() {
+					icon = "📝"
 				}
-				// Extract the range from the key
-				parts := strings.Split(rangeKey, ":")
-				if len(parts) >= 2 {
-					rangePart := parts[len(parts)-1]
-					var start, end int
-					if _, err := fmt.Sscanf(rangePart, "%d-%d", &start, &end); err == nil {
-						// This is a range comment
-						// Show it after the last line of the range
-						if actualLineNumber == end {
-							processedRanges[rangeKey] = true
-							for _, comment := range comments {
-								commentLine := commentStyle.Render(fmt.Sprintf("💬 [lines %d-%d] %s", start+1, end+1, comment))
-								result = append(result, commentLine)
-							}
-						}
-					}
+				text = c.Body
+				if loc.isRange() {
+					text = fmt.Sprintf("[%s] %s", loc.label(), c.Body)
 				}
+				text = fmt.Sprintf("%s %s", icon, text)
 			}
+			if c.ReplyTo == 0 && t.resolved() {
+				text = "✓ resolved · " + text
+			}
+
+			style := commentStyle
+			switch {
+			case c.ID == focused:
+				style = focusedCommentStyle
+			case t.resolved():
+				style = resolvedCommentStyle
+			case m.outdated[loc]:
+				style = outdatedCommentStyle
+			}
+			// Indent with the margin so every line of a multi-line comment lines up, and
+			// replies sit under their thread
+			margin := indent
+			if c.ReplyTo != 0 {
+				margin += replyIndent
+			}
+			style = style.MarginLeft(style.GetMarginLeft() + margin)
+			lines = append(lines, renderComment(style, c.Severity, text))
 		}
 	}
+	return lines
+}
 
-	return strings.Join(result, "\n")
+// Number of columns replies are indented under the comment that starts their thread
+const replyIndent = 4
+
+// renderComment renders a comment in its box, with a badge showing its severity in front
+func renderComment(style lipgloss.Style, sev severity, text string) string {
+	badge := sev.badge()
+	if badge == "" {
+		return style.Render(text)
+	}
+	// The badge sits outside the box so its colors don't cut off the box's background
+	box := style.MarginLeft(0).Render(text)
+	return lipgloss.NewStyle().
+		MarginLeft(style.GetMarginLeft()).
+		Render(lipgloss.JoinHorizontal(lipgloss.Top, badge, " ", box))
+}
+
+// isSplitContentRow reports whether a row of the side-by-side view shows code in two columns
+func (m model) isSplitContentRow(row int) bool {
+	fd, exists := m.diffs[m.currentFile()]
+	if !m.splitView || !exists || row < 0 || row >= len(fd.splitRows) {
+		return false
+	}
+	return fd.splitRows[row].Kind == diff.KindContext
+}
+
+// renderSplitCursorRow renders a side-by-side row with the cursor column highlighted and
+// an arrow on the separator pointing at it
+func (m model) renderSplitCursorRow(row int) string {
+	fd := m.diffs[m.currentFile()]
+	splitRow := fd.splitRows[row]
+	columnWidth := diff.SplitColumnWidth(m.contentWidth())
+	digits := diff.LineNumberDigits(fd.rows)
+
+	left := diff.RenderSplitCell(columnWidth, digits, splitRow.Left, diff.SideOld, m.logger)
+	right := diff.RenderSplitCell(columnWidth, digits, splitRow.Right, diff.SideNew, m.logger)
+	if m.cursorSide == diff.SideOld {
+		return cursorLineStyle.Render(left) + splitCursorStyle.Render("◀│ ") + right
+	}
+	return left + splitCursorStyle.Render(" │▶") + cursorLineStyle.Render(right)
 }
 
 // renderFileList renders the file selection list
@@ -104,22 +197,87 @@ func (m model) renderFileList() string {
 	b.WriteString(header)
 	b.WriteString("\n\n")
 
-	// File list
-	for i, file := range m.changedFiles {
+	// Summary of totals across all files
+	b.WriteString(fileListSummaryStyle.Render(m.fileListSummary()))
+	b.WriteString("\n\n")
+
+	// Review summary (if written)
+	if summary, ok := m.summary(); ok {
+		b.WriteString(commentStyle.Render("📋 " + summary.Body))
+		b.WriteString("\n\n")
+	}
+
+	// Fuzzy filter input
+	if m.fileFilterMode {
+		b.WriteString(fileListItemStyle.Render(m.fileFilter.View()))
+		b.WriteString("\n\n")
+	}
+
+	entries := m.fileListEntries()
+
+	// Align names so statuses and stats line up in columns
+	nameWidth := 0
+	for _, entry := range entries {
+		nameWidth = max(nameWidth, entry.depth*2+lipgloss.Width(m.fileListEntryName(entry)))
+	}
+
+	// File tree
+	if len(entries) == 0 {
+		b.WriteString(fileListItemStyle.Render("  No matching files"))
+		b.WriteString("\n")
+	}
+	for i, entry := range entries {
+		indent := strings.Repeat("  ", entry.depth)
+		name := indent + m.fileListEntryName(entry)
+		padding := strings.Repeat(" ", nameWidth-lipgloss.Width(name))
+
+		var label, detail string
+		var stat fileStat
+		if entry.node.isDir() {
+			var files int
+			stat, files = m.dirStat(entry.node)
+			label = fmt.Sprintf("%d files", files)
+		} else {
+			stat = m.fileStat(entry.node.fileIndex)
+			label = statusLabel(m.changedFiles[entry.node.fileIndex])
+		}
+		if stat.comments > 0 {
+			detail = fmt.Sprintf("  💬 %d", stat.comments)
+		}
+		if !entry.node.isDir() && m.reviewed[entry.node.path] {
+			detail += "  ✓"
+		}
+
 		if i == m.fileListCursor {
-			// Highlight the current selection
-			line := fileListSelectedStyle.Render(fmt.Sprintf("  %s", file))
-			b.WriteString(line)
+			// Highlight the current selection; every segment carries the selection background
+			// so fuzzy match highlighting doesn't break it
+			name = indent + highlightMatches(m.fileListEntryName(entry), entry.matches, fileListSelectedSegmentStyle, fileListSelectedMatchStyle)
+			line := fileListSelectedSegmentStyle.Render(fmt.Sprintf("  %-10s ", label)) + name +
+				fileListSelectedSegmentStyle.Render(fmt.Sprintf("%s  %s%s", padding, formatDiffStat(stat), detail))
+			b.WriteString(fileListSelectedStyle.Render(line))
 		} else {
-			line := fileListItemStyle.Render(fmt.Sprintf("  %s", file))
-			b.WriteString(line)
+			name = indent + highlightMatches(m.fileListEntryName(entry), entry.matches, lipgloss.NewStyle(), fileListMatchStyle)
+			diffStat := formatDiffStat(stat)
+			if stat.loaded && !stat.binary {
+				diffStat = diffStatAddedStyle.Render(fmt.Sprintf("+%d", stat.added)) + " " +
+					diffStatDeletedStyle.Render(fmt.Sprintf("-%d", stat.deleted))
+			}
+			styledLabel := fileListDirStyle.Render(fmt.Sprintf("%-10s", label))
+			if !entry.node.isDir() {
+				styledLabel = fileStatusStyle(label).Render(fmt.Sprintf("%-10s", label))
+			}
+			line := fmt.Sprintf("  %s %s%s  %s%s", styledLabel, name, padding, diffStat, detail)
+			b.WriteString(fileListItemStyle.Render(line))
 		}
 		b.WriteString("\n")
 	}
 
 	// Footer
 	b.WriteString("\n")
-	helpText := "↑↓ navigate | ↵ select | esc cancel"
+	helpText := "↑↓ navigate | ←→ collapse/expand | / filter | ↵ select | esc cancel"
+	if m.fileFilterMode {
+		helpText = "type to filter | ↑↓ navigate | ↵ open match | esc clear filter"
+	}
 	footer := footerStyle.Render(helpText)
 	b.WriteString(footer)
 
@@ -127,6 +285,115 @@ func (m model) renderFileList() string {
 	return modalContainer.Render(b.String())
 }
 
+// fileListEntryName returns the display name of a file picker row, with an expand marker for
+// directories and the original path for renamed files
+func (m model) fileListEntryName(entry fileListEntry) string {
+	if !entry.node.isDir() {
+		if orig := m.changedFiles[entry.node.fileIndex].OrigPath; orig != "" {
+			return fmt.Sprintf("%s ← %s", entry.node.name, orig)
+		}
+		return entry.node.name
+	}
+	if m.collapsedDirs[entry.node.path] {
+		return "▸ " + entry.node.name + "/"
+	}
+	return "▾ " + entry.node.name + "/"
+}
+
+// highlightMatches renders text with the runes at the given positions in the match style
+func highlightMatches(text string, positions []int, base, match lipgloss.Style) string {
+	if len(positions) == 0 {
+		return base.Render(text)
+	}
+
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+
+	var b strings.Builder
+	var segment []rune
+	segmentMatched := false
+	flush := func() {
+		if len(segment) == 0 {
+			return
+		}
+		if segmentMatched {
+			b.WriteString(match.Render(string(segment)))
+		} else {
+			b.WriteString(base.Render(string(segment)))
+		}
+		segment = segment[:0]
+	}
+
+	for i, r := range []rune(text) {
+		if matched[i] != segmentMatched {
+			flush()
+			segmentMatched = matched[i]
+		}
+		segment = append(segment, r)
+	}
+	flush()
+
+	return b.String()
+}
+
+// renderNotes renders the notes on the current file and its outdated comments. They take at
+// most half the room left for the diff, with any that don't fit counted in a final line.
+func (m model) renderNotes() []string {
+	focused := 0
+	if c, _, ok := m.focusedComment(); ok {
+		focused = c.ID
+	}
+	notes := m.renderThreads(fileLocation(m.currentFile()), 0, focused)
+	for _, loc := range m.sortedCommentLocations() {
+		if loc.File == m.currentFile() && m.outdated[loc] {
+			notes = append(notes, m.renderThreads(loc, 0, focused)...)
+		}
+	}
+	if m.height == 0 {
+		return notes
+	}
+
+	limit := m.diffHeight() / 2
+	height := 0
+	for _, note := range notes {
+		height += lipgloss.Height(note)
+	}
+	if height <= limit {
+		return notes
+	}
+
+	// Keep the notes that fit above the line counting the rest
+	height = 1
+	for i, note := range notes {
+		if height += lipgloss.Height(note); height > limit {
+			return append(notes[:i:i], hiddenNotesStyle.Render(fmt.Sprintf("… %d more notes", len(notes)-i)))
+		}
+	}
+	return notes
+}
+
+// renderCommentInput renders the box a comment is written in, with a prompt saying what it's on
+func (m model) renderCommentInput() string {
+	commentPrompt := fmt.Sprintf("💬 Adding comment to %s:", m.commentTarget.label())
+	if _, ok := suggestedLines(m.commentInput.Value()); ok {
+		commentPrompt = fmt.Sprintf("💡 Suggesting a change to %s:", m.commentTarget.label())
+	}
+	if m.editingComment != 0 {
+		commentPrompt = fmt.Sprintf("✏️  Editing comment on %s:", m.commentTarget.label())
+	} else if m.replyingTo != 0 {
+		commentPrompt = fmt.Sprintf("↩ Replying to thread on %s:", m.commentTarget.label())
+	} else if m.commentTarget.isSummary() {
+		commentPrompt = "📋 Review summary:"
+	} else if m.commentTarget.isFileLevel() {
+		commentPrompt = fmt.Sprintf("📝 Adding note on %s:", m.commentTarget.File)
+	}
+	return commentInputStyle.Render(
+		fmt.Sprintf("%s\n%s\n\n%s", commentPrompt, m.commentInput.View(), renderSeverityPicker(m.commentSeverity)),
+	)
+}
+
 // View renders the current state of the model
 func (m model) View() string {
 	// Handle error state
@@ -134,9 +401,14 @@ func (m model) View() string {
 		return modalContainer.Render(errorStyle.Render(fmt.Sprintf("❌ Error: %v\n\nPress q to quit.", m.err)))
 	}
 
+	// Offer to resume a saved review before anything else
+	if m.pendingSession != nil {
+		return m.renderResumePrompt()
+	}
+
 	// Handle no changes state
 	if len(m.changedFiles) == 0 {
-		return modalContainer.Render(infoStyle.Render("ℹ️  No unstaged changes found.\n\nPress q to quit."))
+		return modalContainer.Render(infoStyle.Render(fmt.Sprintf("ℹ️  No changes found (%s).\n\nPress q to quit.", m.mode)))
 	}
 
 	// Handle not ready state (terminal size not yet known)
@@ -153,33 +425,56 @@ func (m model) View() string {
 	var b strings.Builder
 
 	// Header: File counter and name (prominent)
-	currentFile := m.changedFiles[m.currentIndex]
-	headerText := fmt.Sprintf("📄 File %d/%d: %s", m.currentIndex+1, len(m.changedFiles), currentFile)
+	currentFile := m.changedFiles[m.currentIndex].DisplayName()
+	headerText := fmt.Sprintf("📄 File %d/%d: %s · %s", m.currentIndex+1, len(m.changedFiles), currentFile, m.mode)
+	if m.reviewed[m.currentFile()] {
+		headerText += " · ✓ reviewed"
+	}
+	if outdated := m.outdatedCount(m.currentFile()); outdated > 0 {
+		headerText += fmt.Sprintf(" · ⚠ %d outdated", outdated)
+	}
+	if resolved := m.resolvedThreadCount(m.currentFile()); resolved > 0 && m.hideResolved {
+		headerText += fmt.Sprintf(" · ✓ %d resolved hidden (H)", resolved)
+	}
 	header := headerStyle.Width(m.width).Render(headerText)
 	b.WriteString(header)
 	b.WriteString("\n")
 
+	// Notes on the whole file and outdated comments are shown under its header, so they stay
+	// in view however far the diff is scrolled
+	for _, note := range m.renderNotes() {
+		b.WriteString(note)
+		b.WriteString("\n")
+	}
+
 	// Viewport: Diff content with cursor highlighting
 	b.WriteString(m.renderWithCursor())
 	b.WriteString("\n")
 
 	// Comment input area (if in comment mode)
 	if m.commentMode {
-		var commentPrompt string
-		if m.commentEndLine >= 0 && m.commentEndLine != m.commentLine {
-			// Range comment
-			commentPrompt = fmt.Sprintf("💬 Adding comment to lines %d-%d:", m.commentLine+1, m.commentEndLine+1)
-		} else {
-			// Single line comment
-			commentPrompt = fmt.Sprintf("💬 Adding comment to line %d:", m.commentLine+1)
-		}
-		inputArea := commentInputStyle.Render(
-			fmt.Sprintf("%s\n%s", commentPrompt, m.commentInput.View()),
-		)
-		b.WriteString(inputArea)
+		b.WriteString(m.renderCommentInput())
 		b.WriteString("\n")
 	}
 
+	// Delete confirmation (if a comment is about to be deleted)
+	if m.confirmDelete != 0 {
+		if c, loc, ok := m.findComment(m.confirmDelete); ok {
+			prompt := fmt.Sprintf("🗑  Delete comment on %s?\n%s", loc.label(), c.Body)
+			replies := 0
+			for _, reply := range m.comments[loc] {
+				if reply.ReplyTo == c.ID {
+					replies++
+				}
+			}
+			if replies > 0 {
+				prompt = fmt.Sprintf("🗑  Delete thread on %s and its %d replies?\n%s", loc.label(), replies, c.Body)
+			}
+			b.WriteString(commentInputStyle.Render(prompt))
+			b.WriteString("\n")
+		}
+	}
+
 	// Status message (if present)
 	if m.statusMessage != "" {
 		statusLine := statusStyle.Render(m.statusMessage)
@@ -188,11 +483,23 @@ func (m model) View() string {
 	}
 
 	// Footer: Help text
-	helpText := "tab files | n next | p prev | jk move | v select | c comment | s save | y copy | q quit"
+	helpText := "tab files | n next | p prev | jk move | ctrl+d/ctrl+u ½ page | t split | v select | c comment | F file | O summary | S suggest | E $EDITOR | m reviewed | u undo | H hide resolved | r refresh | R refresh all | s save | y copy | q quit"
+	if m.splitView {
+		helpText = "tab files | n next | p prev | jk move | ctrl+d/ctrl+u ½ page | hl side | t unified | v select | c comment | F file | O summary | S suggest | E $EDITOR | m reviewed | u undo | H hide resolved | r refresh | R refresh all | s save | y copy | q quit"
+	}
+	if c, _, ok := m.focusedComment(); ok {
+		prefix := "a reply | x resolve | e edit | d delete | [ ] other comment | "
+		if _, isSuggestion := suggestedLines(c.Body); isSuggestion {
+			prefix = "A apply | " + prefix
+		}
+		helpText = prefix + helpText
+	}
 	if m.commentMode {
-		helpText = "↵ save | esc cancel"
+		helpText = "ctrl+s or alt+↵ save | ↵ new line | tab severity | esc cancel"
+	} else if m.confirmDelete != 0 {
+		helpText = "y delete | n/esc keep"
 	} else if m.selectionMode {
-		helpText = "↑↓ extend selection | 💬 comment selection | v/esc exit selection"
+		helpText = "↑↓ extend selection | 💬 comment selection | E $EDITOR | v/esc exit selection"
 	}
 	footer := footerStyle.Width(m.width).Render(helpText)
 	b.WriteString(footer)